@@ -0,0 +1,436 @@
+// Package backend builds sessions described by tmuxg session models out of
+// any terminal multiplexer that implements Backend.
+package backend
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// Backend is a terminal multiplexer that a session is built out of. A
+// Backend is bound to the session it builds. Windows and panes are
+// addressed by targets, which are whatever the Backend returns on creating
+// them.
+//
+// A Backend may defer commands until Flush is called, so that it can send
+// them together.
+type Backend interface {
+	// CreateSession creates the session, with w as its first window,
+	// and returns the window's target.
+	CreateSession(w *config.Window) (string, error)
+
+	// CreateWindow creates the window w at the given index, or after the
+	// session's last window if index is negative, and returns its
+	// target.
+	CreateWindow(index int, w *config.Window) (string, error)
+
+	// SplitPane splits the window or pane target, running command in
+	// the new pane in the directory cwd, and returns the new pane's
+	// target.
+	SplitPane(target, cwd, command string) (string, error)
+
+	// SendKeys types keystrokes into the window or pane target.
+	SendKeys(target string, keystrokes []string) error
+
+	// SendText types text into the window or pane target verbatim,
+	// without taking any of it for key names.
+	SendText(target, text string) error
+
+	// Paste pastes text into the window or pane target, as a terminal
+	// pastes, so that applications that tell pastes from typing don't,
+	// say, auto-indent it.
+	Paste(target, text string) error
+
+	// Focus selects the window or pane target.
+	Focus(target string) error
+
+	// Flush carries out any commands that have been deferred.
+	Flush() error
+
+	// Attach connects the terminal to the session.
+	Attach(opts AttachOptions) error
+
+	// Query returns the state of the session.
+	Query() (*State, error)
+
+	// Kill kills the session.
+	Kill() error
+}
+
+// OutputLogger is implemented by Backends that can log a window's output to
+// a file.
+type OutputLogger interface {
+	// LogOutput appends everything the window or pane target shows to
+	// the file path.
+	LogOutput(target, path string) error
+}
+
+// Waiter is implemented by Backends whose windows can signal each other.
+type Waiter interface {
+	// WaitFor waits until channel is signalled, or fails with
+	// ErrWaitTimeout as the cause once timeout has passed.
+	WaitFor(channel string, timeout time.Duration) error
+}
+
+// ErrWaitTimeout is the cause of errors from a Waiter that gave up waiting.
+var ErrWaitTimeout = errgo.New("timed out waiting")
+
+// AttachOptions control how a Backend attaches to a session. Backends
+// ignore options that don't apply to them.
+type AttachOptions struct {
+	// AllowNested allows attaching from inside another multiplexer.
+	AllowNested bool
+
+	// ReadOnly attaches a client that can't type into the session.
+	ReadOnly bool
+
+	// DetachOthers detaches any other clients attached to the session.
+	DetachOthers bool
+}
+
+// State is the state of a session.
+type State struct {
+	// Running is whether the session is running.
+	Running bool
+
+	// Windows holds the names of the session's windows, in order.
+	Windows []string
+}
+
+// WindowCwd returns the directory a window starts in.
+func WindowCwd(s *config.Session, w *config.Window) string {
+	if w.Cwd != "" {
+		return s.ExpandEnv(w.Cwd)
+	}
+	return s.ExpandEnv(s.Cwd)
+}
+
+// WindowCommand returns the shell command a window runs, in its container
+// or Vagrant machine if it has one, otherwise in the session's, once its
+// gate passes.
+func WindowCommand(s *config.Session, w *config.Window) string {
+	command := s.ExpandEnv(w.Command)
+	switch {
+	case w.Container != nil:
+		command = containerCommand(s, w, w.Container, command)
+	case w.VagrantSSH != "":
+		command = config.VagrantCommand(s.ExpandEnv(w.VagrantSSH), command)
+	case s.Container != nil:
+		command = containerCommand(s, w, s.Container, command)
+	case s.Vagrant:
+		command = config.VagrantCommand("", command)
+	}
+	if w.Gate != "" {
+		command = w.Gate + " && " + command
+	}
+	return command
+}
+
+func containerCommand(s *config.Session, w *config.Window, c *config.Container, command string) string {
+	env := make(map[string]string)
+	for k := range s.Environment {
+		env[k] = s.Getenv(k)
+	}
+	return c.Command(command, WindowCwd(s, w), env, s.ExpandEnv)
+}
+
+// focus selects the window or pane named by name, which is a window's name,
+// or a window's name, a dot and a pane's index or title, as splitPanes adds
+// them to byName. Without a name, the first window is selected.
+func focus(b Backend, name, first string, byName map[string]string) error {
+	if name == "" {
+		return b.Focus(first)
+	}
+	target, ok := byName[name]
+	if !ok {
+		// Validated sessions only focus a window they don't name if
+		// it's repeated for worktrees or pods, and found none.
+		log.Printf("no window or pane %q to focus", name)
+		return b.Focus(first)
+	}
+	return b.Focus(target)
+}
+
+// splitPanes splits the panes of the window w, created at target, off it,
+// and types their keystrokes. Their targets are added to byName as the
+// window's name, a dot and the pane's index, counting the window's own pane
+// as 0, and by title if they have one.
+func splitPanes(b Backend, s *config.Session, w *config.Window, target string, byName map[string]string) error {
+	byName[w.Name+".0"] = target
+	for i := range w.Panes {
+		p := &w.Panes[i]
+		pw := PaneWindow(w, p)
+		paneTarget, err := b.SplitPane(target, WindowCwd(s, pw), WindowCommand(s, pw))
+		if err != nil {
+			return errgo.Notef(err, "failed to split pane %d of window %q", i+1, w.Name)
+		}
+		byName[fmt.Sprintf("%s.%d", w.Name, i+1)] = paneTarget
+		if p.Title != "" {
+			byName[w.Name+"."+p.Title] = paneTarget
+		}
+		if len(p.Keystrokes) > 0 {
+			err = SendKeystrokes(b, paneTarget, p.Keystrokes)
+			if err != nil {
+				return errgo.Notef(err, "failed to send keystrokes to pane %d of window %q", i+1, w.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// PaneWindow returns the window w as the pane p sees it: running p's
+// command, in p's working directory if it has one.
+func PaneWindow(w *config.Window, p *config.Pane) *config.Window {
+	pw := *w
+	pw.Command = p.Command
+	if p.Cwd != "" {
+		pw.Cwd = p.Cwd
+	}
+	return &pw
+}
+
+// setUpWindow logs the output of the window w, created at target, pastes
+// into it and sends its keystrokes. byName holds the targets of the
+// session's windows by name, for keystrokes aimed at another window.
+func setUpWindow(b Backend, s *config.Session, w *config.Window, target string, byName map[string]string) error {
+	if w.Log != "" {
+		if logger, ok := b.(OutputLogger); ok {
+			path := s.ExpandEnv(w.Log)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(WindowCwd(s, w), path)
+			}
+			err := logger.LogOutput(target, path)
+			if err != nil {
+				return errgo.Notef(err, "failed to log window %q", w.Name)
+			}
+		} else {
+			log.Printf("not logging window %q, as the backend can't", w.Name)
+		}
+	}
+	if w.Paste != "" {
+		err := b.Paste(target, w.Paste)
+		if err != nil {
+			return errgo.Notef(err, "failed to paste into window %q", w.Name)
+		}
+	}
+	if len(w.Keystrokes) > 0 {
+		// Keystrokes go to their own window, unless they're aimed at
+		// another window by name, or at a target the backend
+		// understands.
+		keysTarget := target
+		if w.KeystrokesTarget != "" {
+			keysTarget = w.KeystrokesTarget
+			if t, ok := byName[keysTarget]; ok {
+				keysTarget = t
+			}
+		}
+		err := SendKeystrokes(b, keysTarget, w.Keystrokes)
+		if err != nil {
+			return errgo.Notef(err, "failed to send keystrokes to window %q", w.Name)
+		}
+	}
+	return nil
+}
+
+// AddWindows creates the windows of s that the running session doesn't
+// have, by name, after its last window, and returns their names. Windows
+// repeated for worktrees or pods are left alone, as their names depend on
+// what they find, and so are windows linked from other sessions, which
+// keep their own names.
+func AddWindows(b Backend, s *config.Session) ([]string, error) {
+	state, err := b.Query()
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	have := make(map[string]bool)
+	for _, name := range state.Windows {
+		have[name] = true
+	}
+	var added []string
+	byName := make(map[string]string)
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		if have[w.Name] || w.Worktrees != nil || w.Kubectl != nil || w.LinkWindow != "" {
+			continue
+		}
+		target, err := b.CreateWindow(-1, w)
+		if err != nil {
+			return added, errgo.Notef(err, "failed to create window %q", w.Name)
+		}
+		byName[w.Name] = target
+		err = splitPanes(b, s, w, target, byName)
+		if err != nil {
+			return added, errgo.Mask(err)
+		}
+		err = setUpWindow(b, s, w, target, byName)
+		if err != nil {
+			return added, errgo.Mask(err)
+		}
+		added = append(added, w.Name)
+	}
+	return added, errgo.Mask(b.Flush())
+}
+
+// waitFor waits for the window w's wait-for channel to be signalled, once
+// the windows created so far are running.
+func waitFor(b Backend, w *config.Window) error {
+	channel := w.WaitFor
+	waiter, ok := b.(Waiter)
+	if !ok {
+		log.Printf("not waiting for %q, as the backend can't", channel)
+		return nil
+	}
+	err := b.Flush()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	timeout := w.WaitTimeout
+	if timeout == 0 {
+		timeout = config.DefaultWaitTimeout
+	}
+	log.Printf("waiting for %q", channel)
+	return errgo.Mask(waiter.WaitFor(channel, timeout), errgo.Is(ErrWaitTimeout))
+}
+
+// awaitReady waits for the window w to be ready, once the windows created
+// so far are running.
+func awaitReady(b Backend, s *config.Session, w *config.Window) error {
+	if w.Ready == nil {
+		return nil
+	}
+	err := b.Flush()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	return errgo.Mask(WaitReady(s, w))
+}
+
+// SendKeystrokes types keystrokes into the window or pane target, pausing
+// where they sleep. Keys before a pause are flushed, so that they're typed
+// before it.
+func SendKeystrokes(b Backend, target string, keystrokes []config.Keystroke) error {
+	var keys []string
+	for _, k := range keystrokes {
+		if k.Sleep == 0 && !k.Literal {
+			keys = append(keys, k.Keys)
+			continue
+		}
+		if len(keys) > 0 {
+			err := b.SendKeys(target, keys)
+			if err != nil {
+				return errgo.Mask(err)
+			}
+			keys = nil
+		}
+		if k.Literal {
+			err := b.SendText(target, k.Keys)
+			if err != nil {
+				return errgo.Mask(err)
+			}
+			continue
+		}
+		err := b.Flush()
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		time.Sleep(k.Sleep)
+	}
+	if len(keys) > 0 {
+		return errgo.Mask(b.SendKeys(target, keys))
+	}
+	return nil
+}
+
+// Running returns whether the session is running.
+func Running(b Backend) bool {
+	state, err := b.Query()
+	return err == nil && state.Running
+}
+
+// Build creates the session and its windows with b, each after the
+// windows it depends on, and then their panes. If that fails part way
+// through, the partially created session is killed, unless keepPartial is
+// set.
+func Build(b Backend, s *config.Session, keepPartial bool) (err error) {
+	if len(s.Windows) == 0 {
+		return errgo.New("no windows configured for this session!")
+	}
+	windows, err := config.OrderWindows(s.Windows)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	ordered := *s
+	ordered.Windows = windows
+	s = &ordered
+	if s.Windows[0].WaitFor != "" {
+		return errgo.Newf("the first window, %q, has no window before it to wait for", s.Windows[0].Name)
+	}
+	first, err := b.CreateSession(&s.Windows[0])
+	if err != nil {
+		return errgo.Notef(err, "failed to start session")
+	}
+	defer func() {
+		if err != nil && !keepPartial {
+			log.Printf("removing partially created session %q", s.Name)
+			if killErr := b.Kill(); killErr != nil {
+				log.Printf("%v", killErr)
+			}
+		}
+	}()
+
+	err = awaitReady(b, s, &s.Windows[0])
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	targets := []string{first}
+	for i := 1; i < len(s.Windows); i++ {
+		if s.Windows[i].WaitFor != "" {
+			err = waitFor(b, &s.Windows[i])
+			if err != nil {
+				return errgo.NoteMask(err, fmt.Sprintf("window %q failed waiting for %q", s.Windows[i].Name, s.Windows[i].WaitFor), errgo.Is(ErrWaitTimeout))
+			}
+		}
+		target, err := b.CreateWindow(i, &s.Windows[i])
+		if err != nil {
+			return errgo.Notef(err, "failed to create window %q", s.Windows[i].Name)
+		}
+		targets = append(targets, target)
+		err = awaitReady(b, s, &s.Windows[i])
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+
+	byName := make(map[string]string)
+	for i, w := range s.Windows {
+		byName[w.Name] = targets[i]
+	}
+	for i := range s.Windows {
+		err = splitPanes(b, s, &s.Windows[i], targets[i], byName)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	for i := range s.Windows {
+		err = setUpWindow(b, s, &s.Windows[i], targets[i], byName)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	err = focus(b, s.Focus, targets[0], byName)
+	if err != nil {
+		return errgo.Notef(err, "failed to set window focus")
+	}
+	err = b.Flush()
+	if err != nil {
+		return errgo.Notef(err, "failed to set up session %q", s.Name)
+	}
+	return nil
+}
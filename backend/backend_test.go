@@ -0,0 +1,169 @@
+package backend_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/backend/backendtest"
+	"github.com/cmars/tmuxg/config"
+)
+
+func testSession(focus string) *config.Session {
+	return &config.Session{
+		Name:  "dev",
+		Cwd:   "/src",
+		Focus: focus,
+		Windows: []config.Window{{
+			Name:    "editor",
+			Command: "vim",
+			Panes: []config.Pane{
+				{Title: "tests", Command: "go test"},
+				{Cwd: "/notes", Command: "bash", Keystrokes: []config.Keystroke{{Keys: "git pull"}, {Keys: "Enter"}}},
+			},
+		}, {
+			Name:    "shell",
+			Command: "bash",
+		}},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		about       string
+		focus       string
+		fail        string
+		keepPartial bool
+		want        []string
+		wantErr     string
+	}{{
+		about: "windows are created, then their panes, and the first is focused",
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+			"SplitPane 0 /src go test",
+			"SplitPane 0 /notes bash",
+			"SendKeys 0.2 git pull Enter",
+			"Focus 0",
+			"Flush",
+		},
+	}, {
+		about: "a window is focused by name",
+		focus: "shell",
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+			"SplitPane 0 /src go test",
+			"SplitPane 0 /notes bash",
+			"SendKeys 0.2 git pull Enter",
+			"Focus 1",
+			"Flush",
+		},
+	}, {
+		about: "a pane is focused by index",
+		focus: "editor.2",
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+			"SplitPane 0 /src go test",
+			"SplitPane 0 /notes bash",
+			"SendKeys 0.2 git pull Enter",
+			"Focus 0.2",
+			"Flush",
+		},
+	}, {
+		about: "a pane is focused by title",
+		focus: "editor.tests",
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+			"SplitPane 0 /src go test",
+			"SplitPane 0 /notes bash",
+			"SendKeys 0.2 git pull Enter",
+			"Focus 0.1",
+			"Flush",
+		},
+	}, {
+		about: "a failed build is killed",
+		fail:  "CreateWindow 1 shell",
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+			"Kill",
+		},
+		wantErr: `failed to create window "shell": boom`,
+	}, {
+		about: "a failed pane kills the session",
+		fail:  "SplitPane 0 /notes bash",
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+			"SplitPane 0 /src go test",
+			"SplitPane 0 /notes bash",
+			"Kill",
+		},
+		wantErr: `failed to split pane 2 of window "editor": boom`,
+	}, {
+		about:       "a failed build is kept if asked",
+		fail:        "CreateWindow 1 shell",
+		keepPartial: true,
+		want: []string{
+			"CreateSession editor",
+			"CreateWindow 1 shell",
+		},
+		wantErr: `failed to create window "shell": boom`,
+	}, {
+		about: "a session that fails to start has nothing to kill",
+		fail:  "CreateSession editor",
+		want: []string{
+			"CreateSession editor",
+		},
+		wantErr: "failed to start session: boom",
+	}}
+	for _, test := range tests {
+		t.Run(test.about, func(t *testing.T) {
+			b := &backendtest.Backend{
+				Fail: func(call string) error {
+					if call == test.fail {
+						return errgo.New("boom")
+					}
+					return nil
+				},
+			}
+			err := backend.Build(b, testSession(test.focus), test.keepPartial)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("got error %v, want %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(b.Calls, test.want) {
+				t.Errorf("got calls\n%s\nwant\n%s", strings.Join(b.Calls, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestAddWindows(t *testing.T) {
+	b := &backendtest.Backend{}
+	s := testSession("")
+	_, err := b.CreateSession(&s.Windows[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := backend.AddWindows(b, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"shell"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("got %q, want %q", added, want)
+	}
+	want := []string{"CreateSession editor", "CreateWindow -1 shell", "Flush"}
+	if !reflect.DeepEqual(b.Calls, want) {
+		t.Errorf("got calls %q, want %q", b.Calls, want)
+	}
+}
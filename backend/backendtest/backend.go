@@ -0,0 +1,121 @@
+// Package backendtest provides a fake Backend for testing code that builds
+// sessions.
+package backendtest
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+var _ backend.Backend = (*Backend)(nil)
+
+// Backend is a backend.Backend that records what it is asked to do, and
+// keeps track of the windows of the session it pretends to build.
+type Backend struct {
+	// Calls holds a description of every call made, in order, such as
+	// "CreateWindow 1 editor".
+	Calls []string
+
+	// Fail, if set, is called with the description of each call, and
+	// the call fails with the error it returns, if any.
+	Fail func(call string) error
+
+	state backend.State
+	panes map[string]int
+}
+
+func (b *Backend) call(format string, args ...interface{}) error {
+	call := fmt.Sprintf(format, args...)
+	b.Calls = append(b.Calls, call)
+	if b.Fail != nil {
+		return b.Fail(call)
+	}
+	return nil
+}
+
+// CreateSession implements backend.Backend.
+func (b *Backend) CreateSession(w *config.Window) (string, error) {
+	if b.state.Running {
+		return "", errgo.New("duplicate session")
+	}
+	err := b.call("CreateSession %s", w.Name)
+	if err != nil {
+		return "", err
+	}
+	b.state = backend.State{Running: true, Windows: []string{w.Name}}
+	return "0", nil
+}
+
+// CreateWindow implements backend.Backend.
+func (b *Backend) CreateWindow(index int, w *config.Window) (string, error) {
+	err := b.call("CreateWindow %d %s", index, w.Name)
+	if err != nil {
+		return "", err
+	}
+	b.state.Windows = append(b.state.Windows, w.Name)
+	return fmt.Sprint(index), nil
+}
+
+// SplitPane implements backend.Backend.
+func (b *Backend) SplitPane(target, cwd, command string) (string, error) {
+	err := b.call("SplitPane %s %s %s", target, cwd, command)
+	if err != nil {
+		return "", err
+	}
+	if b.panes == nil {
+		b.panes = make(map[string]int)
+	}
+	b.panes[target]++
+	return fmt.Sprintf("%s.%d", target, b.panes[target]), nil
+}
+
+// SendKeys implements backend.Backend.
+func (b *Backend) SendKeys(target string, keystrokes []string) error {
+	return b.call("SendKeys %s %s", target, strings.Join(keystrokes, " "))
+}
+
+// SendText implements backend.Backend.
+func (b *Backend) SendText(target, text string) error {
+	return b.call("SendText %s %s", target, text)
+}
+
+// Paste implements backend.Backend.
+func (b *Backend) Paste(target, text string) error {
+	return b.call("Paste %s %s", target, text)
+}
+
+// Focus implements backend.Backend.
+func (b *Backend) Focus(target string) error {
+	return b.call("Focus %s", target)
+}
+
+// Flush implements backend.Backend.
+func (b *Backend) Flush() error {
+	return b.call("Flush")
+}
+
+// Attach implements backend.Backend.
+func (b *Backend) Attach(opts backend.AttachOptions) error {
+	return b.call("Attach")
+}
+
+// Query implements backend.Backend.
+func (b *Backend) Query() (*backend.State, error) {
+	state := b.state
+	return &state, nil
+}
+
+// Kill implements backend.Backend.
+func (b *Backend) Kill() error {
+	err := b.call("Kill")
+	if err != nil {
+		return err
+	}
+	b.state = backend.State{}
+	return nil
+}
@@ -0,0 +1,56 @@
+package backend
+
+import (
+	"strings"
+)
+
+// keyNames are friendly names for keys, and the tmux key names they stand
+// for.
+var keyNames = map[string]string{
+	"\n":        "Enter",
+	"\r":        "Enter",
+	"\t":        "Tab",
+	"Return":    "Enter",
+	"Esc":       "Escape",
+	"Backspace": "BSpace",
+	"Delete":    "DC",
+	"Del":       "DC",
+	"Insert":    "IC",
+	"PageUp":    "PPage",
+	"PgUp":      "PPage",
+	"PageDown":  "NPage",
+	"PgDn":      "NPage",
+	"Shift-Tab": "BTab",
+}
+
+// modifiers are friendly prefixes for modified keys, and the tmux prefixes
+// they stand for.
+var modifiers = []struct {
+	prefix, tmux string
+}{
+	{"Ctrl-", "C-"},
+	{"Ctrl+", "C-"},
+	{"^", "C-"},
+	{"Alt-", "M-"},
+	{"Alt+", "M-"},
+	{"Meta-", "M-"},
+}
+
+// KeyName returns the tmux key name for a keystroke, translating friendly
+// names such as Return, Esc, Ctrl-c and Alt-x, and a lone newline or tab.
+// Any other keystroke is returned as it is.
+func KeyName(k string) string {
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	for _, m := range modifiers {
+		if strings.HasPrefix(k, m.prefix) && len(k) == len(m.prefix)+1 {
+			key := k[len(m.prefix):]
+			if m.tmux == "C-" {
+				key = strings.ToLower(key)
+			}
+			return m.tmux + key
+		}
+	}
+	return k
+}
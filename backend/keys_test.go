@@ -0,0 +1,33 @@
+package backend_test
+
+import (
+	"testing"
+
+	"github.com/cmars/tmuxg/backend"
+)
+
+func TestKeyName(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"Return", "Enter"},
+		{"\n", "Enter"},
+		{"\t", "Tab"},
+		{"Esc", "Escape"},
+		{"PgUp", "PPage"},
+		{"Shift-Tab", "BTab"},
+		{"Ctrl-C", "C-c"},
+		{"Ctrl+a", "C-a"},
+		{"^d", "C-d"},
+		{"Alt-x", "M-x"},
+		{"Meta-X", "M-X"},
+		{"Ctrl-Left", "Ctrl-Left"},
+		{"Enter", "Enter"},
+		{"ls -la", "ls -la"},
+	}
+	for _, test := range tests {
+		if got := backend.KeyName(test.key); got != test.want {
+			t.Errorf("KeyName(%q): got %q, want %q", test.key, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// readyInterval is how often a ready check is retried.
+const readyInterval = time.Second
+
+// WaitReady waits for the window w to pass its ready check, if it has one.
+func WaitReady(s *config.Session, w *config.Window) error {
+	if w.Ready == nil {
+		return nil
+	}
+	check, err := readyCheck(s, w)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	timeout := w.Ready.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultReadyTimeout
+	}
+	log.Printf("waiting for window %q to be ready", w.Name)
+	deadline := time.Now().Add(timeout)
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errgo.Notef(err, "window %q not ready after %v", w.Name, timeout)
+		}
+		time.Sleep(readyInterval)
+	}
+}
+
+// readyCheck returns a function that checks once whether the window is
+// ready.
+func readyCheck(s *config.Session, w *config.Window) (func() error, error) {
+	r := w.Ready
+	cwd := WindowCwd(s, w)
+	switch {
+	case r.TCP != "" && r.File == "" && r.Command == "":
+		return func() error {
+			conn, err := net.DialTimeout("tcp", r.TCP, readyInterval)
+			if err != nil {
+				return errgo.Mask(err)
+			}
+			return conn.Close()
+		}, nil
+	case r.File != "" && r.TCP == "" && r.Command == "":
+		path := s.ExpandEnv(r.File)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		return func() error {
+			_, err := os.Stat(path)
+			return errgo.Mask(err)
+		}, nil
+	case r.Command != "" && r.TCP == "" && r.File == "":
+		return func() error {
+			cmd := exec.Command("sh", "-c", r.Command)
+			cmd.Dir = cwd
+			cmd.Env = s.Env()
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				return errgo.Notef(err, "%s", out)
+			}
+			return nil
+		}, nil
+	}
+	return nil, errgo.Newf("window %q ready check must be exactly one of tcp, file or command", w.Name)
+}
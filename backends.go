@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os/exec"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+	"github.com/cmars/tmuxg/wezterm"
+)
+
+// newBackend returns the backend that builds the session, after checking
+// that its multiplexer is installed and up to the session's requirements.
+func newBackend(conf *config.Config, s *config.Session) (backend.Backend, error) {
+	switch s.Backend {
+	case "", config.BackendTmux:
+		if _, err := exec.LookPath(conf.Tmux()); err != nil {
+			return nil, errgo.WithCausef(err, errTmuxMissing, "")
+		}
+		ts := tmux.New(s, conf.Tmux())
+		err := ts.CheckRequirements()
+		if errgo.Cause(err) == tmux.ErrRequirement {
+			return nil, errgo.WithCausef(err, errParse, "")
+		} else if err != nil {
+			return nil, errgo.Mask(err, errgo.Is(tmux.ErrVersion))
+		}
+		return ts, nil
+	case config.BackendWezterm:
+		ws := wezterm.New(s)
+		if _, err := exec.LookPath(ws.Bin); err != nil {
+			return nil, errgo.Notef(err, "wezterm not found")
+		}
+		return ws, nil
+	default:
+		return nil, errgo.WithCausef(nil, errParse, "unknown backend %q", s.Backend)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// captureCommand prints what the panes of a session, or of one of its
+// windows, show, or writes it to a file for each pane.
+func captureCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	history := fs.Bool("history", false, "include each pane's scrollback history")
+	dir := fs.String("o", "", "write each pane to a file in this directory, rather than to standard output")
+	args, err := parseCommandFlags("capture", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if len(args) != 2 {
+		err = commandArgs("capture", args, 1)
+		if err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	session, ok := b.(*tmux.Session)
+	if !ok {
+		return errgo.Newf("capturing needs the tmux backend")
+	}
+	if !backend.Running(b) {
+		return errgo.Newf("session %q is not running", s.Name)
+	}
+
+	listArgs := []string{"list-panes", "-s", "-t", s.Name}
+	if len(args) == 2 {
+		listArgs = []string{"list-panes", "-t", s.Name + ":" + args[1]}
+	}
+	out, err := session.Output(append(listArgs, "-F", "#{window_index}.#{pane_index} #{window_name}")...)
+	if err != nil {
+		return errgo.Notef(err, "failed to list panes of session %q", s.Name)
+	}
+	panes := strings.Split(strings.TrimSpace(string(out)), "\n")
+	for i, pane := range panes {
+		fields := strings.SplitN(pane, " ", 2)
+		captureArgs := []string{"capture-pane", "-p", "-J", "-t", s.Name + ":" + fields[0]}
+		if *history {
+			captureArgs = append(captureArgs, "-S", "-")
+		}
+		text, err := session.Output(captureArgs...)
+		if err != nil {
+			return errgo.Notef(err, "failed to capture pane %s", fields[0])
+		}
+
+		if *dir != "" {
+			path := filepath.Join(*dir, s.Name+"-"+fields[0]+".txt")
+			err = ioutil.WriteFile(path, text, 0644)
+			if err != nil {
+				return errgo.Notef(err, "failed to write %q", path)
+			}
+			continue
+		}
+		if len(panes) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", pane)
+		}
+		os.Stdout.Write(text)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// command is a tmuxg subcommand, run as "tmuxg <name> args...". Any other
+// first argument names a session to start, or failing that, a plugin. So
+// don't name a session after a command.
+type command struct {
+	usage string
+	run   func(conf *config.Config, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"capture":           {"capture <session> [window] [-history] [-o dir]", captureCommand},
+		"cp":                {"cp <session> <new session>", cpCommand},
+		"env":               {"env <session> [-format shell|json]", envCommand},
+		"export":            {"export -format <format> <session>", exportCommand},
+		"import":            {"import <format> [file...]", importCommand},
+		"init":              {"init [session] [-template name] [-set name=value...]", initCommand},
+		"install-service":   {"install-service <session>", installServiceCommand},
+		"last":              {"last", lastCommand},
+		"new":               {"new <session> [-template name] [-set name=value...] | new -interactive [session]", newCommand},
+		"pick":              {"pick", pickCommand},
+		"pull":              {"pull", pullCommand},
+		"push":              {"push <session> <host...>", pushCommand},
+		"refresh-pods":      {"refresh-pods <session>", refreshPodsCommand},
+		"rename":            {"rename <session> <new name>", renameCommand},
+		"rm":                {"rm <session> [-force] [-kill]", rmCommand},
+		"run":               {"run <session> <window> [--] <command...> | run -all <session> [--] <command...>", runCommand},
+		"schedule":          {"schedule <session> -at HH:MM [-weekdays] | schedule <session> -remove", scheduleCommand},
+		"self-update":       {"self-update [-key minisign.pub | -insecure] [-force]", selfUpdateCommand},
+		"serve":             {"serve [-listen path]", serveCommand},
+		"show":              {"show <session>", showCommand},
+		"socket":            {"socket <session>", socketCommand},
+		"start":             {"start <session> [-host user@host] [-transport ssh|mosh]", startCommand},
+		"status":            {"status <session>", statusCommand},
+		"stop":              {"stop <session>", stopCommand},
+		"templates":         {"templates list", templatesCommand},
+		"uninstall-service": {"uninstall-service <session>", uninstallServiceCommand},
+		"version":           {"version [-verbose]", versionCommand},
+		"workspace":         {"workspace up <workspace> [-attach session]", workspaceCommand},
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [flags] <session name or file>\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "       %s %s\n", os.Args[0], commands[name].usage)
+	}
+	for _, name := range plugins() {
+		fmt.Fprintf(os.Stderr, "       %s %s ... (plugin)\n", os.Args[0], name)
+	}
+	flag.PrintDefaults()
+}
+
+// commandArgs checks a subcommand was given n arguments.
+func commandArgs(name string, args []string, n int) error {
+	if len(args) != n {
+		return errgo.WithCausef(nil, errUsage, "usage: %s %s", os.Args[0], commands[name].usage)
+	}
+	return nil
+}
+
+// parseCommandFlags parses a subcommand's flags, which may come before,
+// after or between its arguments, and returns the arguments.
+func parseCommandFlags(name string, fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, errgo.WithCausef(err, errUsage, "usage: %s %s", os.Args[0], commands[name].usage)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// parseLeadingFlags parses a subcommand's flags, which must come before its
+// arguments, and returns the arguments, so that flags of a command among
+// them are left alone.
+func parseLeadingFlags(name string, fs *flag.FlagSet, args []string) ([]string, error) {
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, errgo.WithCausef(err, errUsage, "usage: %s %s", os.Args[0], commands[name].usage)
+	}
+	return fs.Args(), nil
+}
+
+// newCommand writes a new session file from a template, and opens it in the
+// editor.
+func newCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	tmpl := fs.String("template", *templateFlag, "template for the new session file")
+	fs.Var(setFlag, "set", "set a template variable, as name=value (may be repeated)")
+	interactive := fs.Bool("interactive", false, "ask about the session instead of using a template")
+	args, err := parseCommandFlags("new", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if *interactive && len(args) < 2 {
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		}
+		_, err := runWizard(os.Stdin, os.Stdout, name)
+		return errgo.Mask(err)
+	}
+	err = commandArgs("new", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if path, err := config.Locate(args[0]); err == nil {
+		return errgo.Newf("session %q already exists in %q", args[0], path)
+	}
+	*templateFlag = *tmpl
+	return errgo.Mask(newSessionFile(args[0]))
+}
+
+// templatesCommand lists the templates available for new session files.
+func templatesCommand(conf *config.Config, args []string) error {
+	err := commandArgs("templates", args, 1)
+	if err == nil && args[0] != "list" {
+		err = commandArgs("templates", nil, 1)
+	}
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	templates, err := listTemplates()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, t := range templates {
+		origin := "built in"
+		if t.user {
+			origin = "user"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.name, origin, t.description)
+	}
+	return errgo.Mask(w.Flush())
+}
+
+// statusCommand prints whether a session is running, and whether it's been
+// set up.
+func statusCommand(conf *config.Config, args []string) error {
+	err := commandArgs("status", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	running := "no"
+	if backend.Running(b) {
+		running = "yes"
+	}
+	setup, err := setupStatus(s)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "session\t%s\n", s.Name)
+	fmt.Fprintf(w, "running\t%s\n", running)
+	fmt.Fprintf(w, "setup\t%s\n", setup)
+	return errgo.Mask(w.Flush())
+}
+
+// socketCommand prints the path of the socket a session's tmux server
+// listens on, so other tools can address it with tmux -S.
+func socketCommand(conf *config.Config, args []string) error {
+	err := commandArgs("socket", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	fmt.Println(tmux.New(s, conf.Tmux()).SocketPath())
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// compose runs a docker compose command on the session's project, in the
+// session's working directory.
+func compose(s *config.Session, args ...string) *exec.Cmd {
+	args = s.Compose.Args(s.ExpandEnv, args...)
+	c := exec.Command(args[0], args[1:]...)
+	c.Env = s.Env()
+	if dir := s.ExpandEnv(s.Cwd); dir != "" {
+		c.Dir = dir
+	}
+	c.Stderr = os.Stderr
+	return c
+}
+
+// composeUp brings up the session's compose project, if it has one, and
+// adds windows showing the logs of its services.
+func composeUp(s *config.Session) error {
+	if s.Compose == nil {
+		return nil
+	}
+	c := compose(s, append([]string{"up", "-d"}, s.Compose.Services...)...)
+	c.Stdout = os.Stdout
+	log.Printf("%v", c)
+	err := c.Run()
+	if err != nil {
+		return errgo.Notef(err, "failed to bring up compose project")
+	}
+
+	services := s.Compose.Services
+	switch s.Compose.Logs {
+	case "", config.ComposeLogsWindows:
+		if len(services) == 0 {
+			out, err := compose(s, "config", "--services").Output()
+			if err != nil {
+				return errgo.Notef(err, "failed to list compose services")
+			}
+			services = strings.Fields(string(out))
+		}
+		for _, service := range services {
+			s.Windows = append(s.Windows, config.Window{
+				Name:    "logs:" + service,
+				Command: composeCommand(s, "logs", "-f", service),
+			})
+		}
+	case config.ComposeLogsWindow:
+		s.Windows = append(s.Windows, config.Window{
+			Name:    "logs",
+			Command: composeCommand(s, append([]string{"logs", "-f"}, services...)...),
+		})
+	case config.ComposeLogsNone:
+	default:
+		return errgo.Newf("unknown compose logs %q", s.Compose.Logs)
+	}
+	return nil
+}
+
+// composeDown takes down the session's compose project, if it has one.
+func composeDown(s *config.Session) error {
+	if s.Compose == nil {
+		return nil
+	}
+	c := compose(s, "down")
+	c.Stdout = os.Stdout
+	log.Printf("%v", c)
+	err := c.Run()
+	if err != nil {
+		return errgo.Notef(err, "failed to take down compose project")
+	}
+	return nil
+}
+
+// composeCommand returns a docker compose command on the session's project
+// as a shell command.
+func composeCommand(s *config.Session, args ...string) string {
+	return config.ShellQuote(s.Compose.Args(s.ExpandEnv, args...)...)
+}
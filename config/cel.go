@@ -0,0 +1,193 @@
+package config
+
+import (
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+
+	"cel.dev/cel-go/cel"
+	"cel.dev/cel-go/common/types"
+	"cel.dev/cel-go/common/types/ref"
+	"gopkg.in/errgo.v1"
+)
+
+// celPattern matches a CEL expression interpolated into a string field, as
+// $[[ expr ]]. Unlike {{ }}, that's left alone by the templates of tmuxg
+// new.
+var celPattern = regexp.MustCompile(`\$\[\[(.*?)\]\]`)
+
+// exprEnv evaluates the CEL expressions in a session file. They may use
+// os, arch and hostname, env(name) for the session's environment
+// variables, and in a counted window, index.
+type exprEnv struct {
+	env  *cel.Env
+	vars map[string]interface{}
+}
+
+func newExprEnv(s *Session) (*exprEnv, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("os", cel.StringType),
+		cel.Variable("arch", cel.StringType),
+		cel.Variable("hostname", cel.StringType),
+		cel.Variable("index", cel.IntType),
+		cel.Function("env",
+			cel.Overload("env_string", []*cel.Type{cel.StringType}, cel.StringType,
+				cel.UnaryBinding(func(name ref.Val) ref.Val {
+					return types.String(s.Getenv(name.Value().(string)))
+				}))),
+	)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	hostname, _ := os.Hostname()
+	return &exprEnv{
+		env: env,
+		vars: map[string]interface{}{
+			"os":       runtime.GOOS,
+			"arch":     runtime.GOARCH,
+			"hostname": hostname,
+			"index":    int64(0),
+		},
+	}, nil
+}
+
+// eval evaluates a CEL expression.
+func (e *exprEnv) eval(expr string) (interface{}, error) {
+	ast, issues := e.env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, errgo.Notef(issues.Err(), "invalid expression %q", expr)
+	}
+	prg, err := e.env.Program(ast)
+	if err != nil {
+		return nil, errgo.Notef(err, "invalid expression %q", expr)
+	}
+	out, _, err := prg.Eval(e.vars)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to evaluate %q", expr)
+	}
+	return out.Value(), nil
+}
+
+// evalBool evaluates a CEL expression that must be true or false.
+func (e *exprEnv) evalBool(expr string) (bool, error) {
+	v, err := e.eval(expr)
+	if err != nil {
+		return false, errgo.Mask(err)
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, errgo.Newf("%q is not true or false", expr)
+	}
+	return b, nil
+}
+
+// evalInt evaluates a CEL expression that must be an integer.
+func (e *exprEnv) evalInt(expr string) (int, error) {
+	v, err := e.eval(expr)
+	if err != nil {
+		return 0, errgo.Mask(err)
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return 0, errgo.Newf("%q is not an integer", expr)
+	}
+	return int(n), nil
+}
+
+// interpolate replaces each $[[ expr ]] in str with the value of expr.
+func (e *exprEnv) interpolate(str string) (string, error) {
+	var err error
+	result := celPattern.ReplaceAllStringFunc(str, func(m string) string {
+		if err != nil {
+			return ""
+		}
+		var v interface{}
+		v, err = e.eval(celPattern.FindStringSubmatch(m)[1])
+		switch v := v.(type) {
+		case string:
+			return v
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case bool:
+			return strconv.FormatBool(v)
+		}
+		if err == nil {
+			err = errgo.Newf("%q is not a string, integer or boolean", m)
+		}
+		return ""
+	})
+	return result, err
+}
+
+// evalExpressions evaluates the session's CEL expressions: repeating
+// windows with a count, dropping those whose when is false, and
+// interpolating $[[ expr ]] in the session's cwd and the windows' names,
+// commands and cwds.
+func (s *Session) evalExpressions() error {
+	if !s.hasExpressions() {
+		return nil
+	}
+	e, err := newExprEnv(s)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	s.Cwd, err = e.interpolate(s.Cwd)
+	if err != nil {
+		return errgo.Notef(err, "cwd")
+	}
+
+	var windows []Window
+	for _, w := range s.Windows {
+		count := 1
+		if w.Count != "" {
+			e.vars["index"] = int64(0)
+			count, err = e.evalInt(w.Count)
+			if err != nil {
+				return errgo.Notef(err, "window %q count", w.Name)
+			}
+		}
+		for i := 0; i < count; i++ {
+			e.vars["index"] = int64(i)
+			if w.When != "" {
+				ok, err := e.evalBool(w.When)
+				if err != nil {
+					return errgo.Notef(err, "window %q when", w.Name)
+				}
+				if !ok {
+					continue
+				}
+			}
+			win := w
+			win.When, win.Count = "", ""
+			for _, field := range []*string{&win.Name, &win.Command, &win.Cwd} {
+				*field, err = e.interpolate(*field)
+				if err != nil {
+					return errgo.Notef(err, "window %q", w.Name)
+				}
+			}
+			windows = append(windows, win)
+		}
+	}
+	s.Windows = windows
+	return nil
+}
+
+// hasExpressions returns whether the session has any CEL expressions, so
+// that sessions without them needn't pay for setting CEL up.
+func (s *Session) hasExpressions() bool {
+	if celPattern.MatchString(s.Cwd) {
+		return true
+	}
+	for _, w := range s.Windows {
+		if w.When != "" || w.Count != "" {
+			return true
+		}
+		for _, field := range []string{w.Name, w.Command, w.Cwd} {
+			if celPattern.MatchString(field) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package config_test
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func TestExpressions(t *testing.T) {
+	tests := []struct {
+		about   string
+		yaml    string
+		want    []config.Window
+		wantErr bool
+	}{{
+		about: "windows whose when is false are dropped",
+		yaml: `
+environment: {MODE: dev}
+windows:
+- name: a
+  when: env("MODE") == "dev"
+- name: b
+  when: env("MODE") == "prod"
+- name: c
+  when: os == "` + runtime.GOOS + `"
+`,
+		want: []config.Window{
+			{Name: "a", Command: "bash"},
+			{Name: "c", Command: "bash"},
+		},
+	}, {
+		about: "windows are repeated count times, with their index",
+		yaml: `
+windows:
+- name: worker-$[[ index ]]
+  command: ./worker --id $[[ index ]]
+  count: 1 + 2
+  when: index != 1
+`,
+		want: []config.Window{
+			{Name: "worker-0", Command: "./worker --id 0"},
+			{Name: "worker-2", Command: "./worker --id 2"},
+		},
+	}, {
+		about: "strings, integers and booleans are interpolated",
+		yaml: `
+environment: {DIR: /src}
+windows:
+- name: shell
+  cwd: $[[ env("DIR") ]]/$[[ 6 * 7 ]]
+  command: echo $[[ 1 < 2 ]]
+`,
+		want: []config.Window{
+			{Name: "shell", Cwd: "/src/42", Command: "echo true"},
+		},
+	}, {
+		about: "template actions are left alone",
+		yaml: `
+windows:
+- name: shell
+  command: echo {{.Name}}
+`,
+		want: []config.Window{
+			{Name: "shell", Command: "echo {{.Name}}"},
+		},
+	}, {
+		about:   "when must be true or false",
+		yaml:    "windows: [{name: a, when: '1'}]",
+		wantErr: true,
+	}, {
+		about:   "count must be an integer",
+		yaml:    "windows: [{name: a, count: '\"2\"'}]",
+		wantErr: true,
+	}, {
+		about:   "interpolated values must be strings, integers or booleans",
+		yaml:    "windows: [{name: 'a$[[ [1] ]]'}]",
+		wantErr: true,
+	}, {
+		about:   "invalid expressions are an error",
+		yaml:    "windows: [{name: a, when: 'nope('}]",
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.about, func(t *testing.T) {
+			s, err := config.LoadContents("dev.yaml", []byte("name: dev\n"+test.yaml))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("got windows %+v, want an error", s.Windows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s.Windows, test.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", s.Windows, test.want)
+			}
+		})
+	}
+}
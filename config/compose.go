@@ -0,0 +1,41 @@
+package config
+
+// Ways of showing the logs of a compose project's services.
+const (
+	ComposeLogsWindows = "windows"
+	ComposeLogsWindow  = "window"
+	ComposeLogsNone    = "none"
+)
+
+// Compose is a docker compose project that a session brings up when it is
+// created, and takes down when it is stopped.
+type Compose struct {
+	// File is the compose file, if not the default in the session's
+	// working directory.
+	File string `yaml:"file,omitempty"`
+
+	// Project is the compose project name, if not the default.
+	Project string `yaml:"project,omitempty"`
+
+	// Services are the services to bring up and show the logs of, or all
+	// of them if empty.
+	Services []string `yaml:"services,omitempty"`
+
+	// Logs is how the services' logs are shown: ComposeLogsWindows, a
+	// window for each service, which is the default; ComposeLogsWindow,
+	// a single window for them all; or ComposeLogsNone.
+	Logs string `yaml:"logs,omitempty"`
+}
+
+// Args returns the arguments of a docker compose command on the project.
+// Environment variables in the project's settings are expanded with expand.
+func (c *Compose) Args(expand func(string) string, args ...string) []string {
+	composeArgs := []string{"docker", "compose"}
+	if c.File != "" {
+		composeArgs = append(composeArgs, "-f", expand(c.File))
+	}
+	if c.Project != "" {
+		composeArgs = append(composeArgs, "-p", expand(c.Project))
+	}
+	return append(composeArgs, args...)
+}
@@ -0,0 +1,105 @@
+// Package config loads tmuxg's settings and session models.
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// Config contains tmuxg-wide settings, read from config.yaml in the tmuxg
+// config directory.
+type Config struct {
+	LogFile string `yaml:"log-file"`
+	Socket  Socket `yaml:"socket"`
+	TmuxBin string `yaml:"tmux-bin"`
+	Backend string `yaml:"backend"`
+
+	// NotifySetup sends a desktop notification when a setup script
+	// finishes, as -notify-setup does.
+	NotifySetup bool `yaml:"notify-setup"`
+
+	// Notify are notified of events in every session's life.
+	Notify []Notifier `yaml:"notify"`
+
+	// SharedSessions, if set, is a git repository of session files shared
+	// by a team, which tmuxg pull keeps a copy of in SharedDir.
+	SharedSessions string `yaml:"shared-sessions"`
+
+	// Trust, if set, is who shared session files must be signed by.
+	Trust Trust `yaml:"trust"`
+}
+
+// Trust is who may sign shared session files, which may run anything. A
+// session file is signed by a signature file next to it: name.yaml.sig for
+// ssh-keygen -Y sign, or name.yaml.minisig for minisign.
+type Trust struct {
+	// AllowedSigners is an ssh-keygen allowed signers file, of the keys
+	// that may sign shared session files, in the tmuxg namespace.
+	AllowedSigners string `yaml:"allowed-signers"`
+
+	// MinisignKey is a minisign public key file, of the key that may sign
+	// shared session files.
+	MinisignKey string `yaml:"minisign-key"`
+}
+
+// Enabled returns whether shared session files must be signed.
+func (t Trust) Enabled() bool {
+	return t.AllowedSigners != "" || t.MinisignKey != ""
+}
+
+// Backends, which build sessions out of different terminal multiplexers.
+const (
+	BackendTmux    = "tmux"
+	BackendWezterm = "wezterm"
+)
+
+// Dir returns the tmuxg config directory, where session files and
+// config.yaml live.
+func Dir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "tmuxg")
+}
+
+// SharedDir returns the directory holding the copy of the shared session
+// files, where sessions not in the tmuxg config directory are looked for.
+func SharedDir() string {
+	return filepath.Join(Dir(), "shared")
+}
+
+// LoadConfig reads config.yaml from the tmuxg config directory. A missing
+// file is an empty config.
+func LoadConfig() (*Config, error) {
+	var conf Config
+
+	confPath := filepath.Join(Dir(), "config.yaml")
+	contents, err := ioutil.ReadFile(confPath)
+	if os.IsNotExist(err) {
+		return &conf, nil
+	} else if err != nil {
+		return nil, errgo.Notef(err, "failed to read config file %q", confPath)
+	}
+	err = yaml.Unmarshal(contents, &conf)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to parse config file %q", confPath)
+	}
+	return &conf, nil
+}
+
+// Tmux returns the tmux executable to run: $TMUXG_TMUX if set, otherwise
+// tmux-bin, otherwise the first tmux on $PATH.
+func (c *Config) Tmux() string {
+	if bin := os.Getenv("TMUXG_TMUX"); bin != "" {
+		return bin
+	}
+	if c.TmuxBin != "" {
+		return os.ExpandEnv(c.TmuxBin)
+	}
+	return "tmux"
+}
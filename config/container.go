@@ -0,0 +1,99 @@
+package config
+
+import (
+	"sort"
+
+	"gopkg.in/errgo.v1"
+)
+
+// Container is a docker container that commands run in, given by one of
+// an image to run a container from, the name of a running container, or a
+// project with a dev container (.devcontainer/devcontainer.json):
+//
+//	container: {image: golang:1.22}
+//	container: {name: myproject-dev}
+//	container: {devcontainer: ${HOME}/src/myproject}
+type Container struct {
+	// Image is an image to run a new container from for each command,
+	// with the working directory mounted into it.
+	Image string `yaml:"image,omitempty"`
+
+	// Name is the name of a running container to execute commands in.
+	Name string `yaml:"name,omitempty"`
+
+	// Devcontainer is the workspace folder of a dev container to execute
+	// commands in, with devcontainer exec.
+	Devcontainer string `yaml:"devcontainer,omitempty"`
+
+	// Workdir is the directory in the container that commands run in.
+	// It defaults to the same path as the working directory, which an
+	// image's container has mounted there, except in a dev container,
+	// where it defaults to the workspace folder.
+	Workdir string `yaml:"workdir,omitempty"`
+}
+
+func (c *Container) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain Container
+	err := unmarshal((*plain)(c))
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, v := range []string{c.Image, c.Name, c.Devcontainer} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errgo.New("container must have one of an image, a name or a devcontainer")
+	}
+	return nil
+}
+
+// Command returns a shell command running command in the container, in the
+// working directory cwd, with the environment variables env. Environment
+// variables in the container's settings are expanded with expand.
+func (c *Container) Command(command, cwd string, env map[string]string, expand func(string) string) string {
+	var names []string
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	workdir := expand(c.Workdir)
+	if c.Devcontainer != "" {
+		args := []string{"devcontainer", "exec", "--workspace-folder", expand(c.Devcontainer)}
+		for _, k := range names {
+			args = append(args, "--remote-env", k+"="+env[k])
+		}
+		if workdir != "" {
+			command = "cd " + ShellQuote(workdir) + " && " + command
+		}
+		return ShellQuote(append(args, "sh", "-c", command)...)
+	}
+
+	if workdir == "" {
+		workdir = cwd
+	}
+	var args []string
+	if c.Image != "" {
+		args = []string{"docker", "run", "-it", "--rm"}
+		if cwd != "" {
+			args = append(args, "-v", cwd+":"+workdir)
+		}
+	} else {
+		args = []string{"docker", "exec", "-it"}
+	}
+	if workdir != "" {
+		args = append(args, "-w", workdir)
+	}
+	for _, k := range names {
+		args = append(args, "-e", k+"="+env[k])
+	}
+	if c.Image != "" {
+		args = append(args, expand(c.Image))
+	} else {
+		args = append(args, expand(c.Name))
+	}
+	return ShellQuote(append(args, "sh", "-c", command)...)
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// hostGetenv returns the value of the environment variable key on the host
+// the session runs on.
+func (s *Session) hostGetenv(key string) string {
+	if v, ok := s.HostEnv[key]; ok {
+		return v
+	}
+	return os.Getenv(key)
+}
+
+// Getenv returns the value of the environment variable key as the session
+// sees it: the session's own, if it declares key, otherwise the host's.
+// The session's variables may refer to the host's, but not each other.
+func (s *Session) Getenv(key string) string {
+	if v, ok := s.Environment[key]; ok {
+		return os.Expand(v, s.hostGetenv)
+	}
+	return s.hostGetenv(key)
+}
+
+// LookupEnv returns the value of the environment variable key as the
+// session sees it, as Getenv does, and whether it's set at all.
+func (s *Session) LookupEnv(key string) (string, bool) {
+	if _, ok := s.Environment[key]; ok {
+		return s.Getenv(key), true
+	}
+	if v, ok := s.HostEnv[key]; ok {
+		return v, true
+	}
+	return os.LookupEnv(key)
+}
+
+// ExpandEnv replaces $var or ${var} in str with the session's environment
+// variables.
+func (s *Session) ExpandEnv(str string) string {
+	return os.Expand(str, s.Getenv)
+}
+
+// Env returns the environment commands run with for the session, as
+// key=value pairs: tmuxg's own, with the session's variables added.
+func (s *Session) Env() []string {
+	overrides := make(map[string]string)
+	for k, v := range s.HostEnv {
+		overrides[k] = v
+	}
+	for k := range s.Environment {
+		overrides[k] = s.Getenv(k)
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		k := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			k = kv[:i]
+		}
+		if _, ok := overrides[k]; !ok {
+			env = append(env, kv)
+		}
+	}
+	var keys []string
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+overrides[k])
+	}
+	return env
+}
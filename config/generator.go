@@ -0,0 +1,88 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+)
+
+// SessionExts are the extensions of session files tmuxg finds by session
+// name, in order of precedence.
+var SessionExts = []string{".yaml", StarlarkExt, ".sh", ".py"}
+
+// generatorInterpreters run generators that aren't executable, by
+// extension.
+var generatorInterpreters = map[string]string{
+	".sh": "sh",
+	".py": "python3",
+}
+
+// isGenerator returns whether the session file at path is a program that
+// prints the session, as YAML, rather than the session itself: a shell or
+// Python script, or any other executable that isn't YAML or Starlark.
+func isGenerator(path string) bool {
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml", StarlarkExt:
+		return false
+	case ".sh", ".py":
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&0111 != 0
+}
+
+// Computed returns whether the session file at path computes the session,
+// as a Starlark session script or a generator, rather than declaring it.
+// Computing it runs code, so it's best not done just to list sessions.
+func Computed(path string) bool {
+	return filepath.Ext(path) == StarlarkExt || isGenerator(path)
+}
+
+// runGenerator runs the session file at path, in its directory, and returns
+// the session YAML it prints. If src is set, it's run instead of path, from
+// a copy.
+func runGenerator(path string, src []byte) ([]byte, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	run := abs
+	if src != nil {
+		f, err := ioutil.TempFile("", "tmuxg-generator*"+filepath.Ext(abs))
+		if err != nil {
+			return nil, errgo.Notef(err, "failed to copy session generator %q", path)
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(src)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), info.Mode()&0777|0700)
+		}
+		if err != nil {
+			return nil, errgo.Notef(err, "failed to copy session generator %q", path)
+		}
+		run = f.Name()
+	}
+	var c *exec.Cmd
+	if interpreter, ok := generatorInterpreters[filepath.Ext(abs)]; ok && info.Mode()&0111 == 0 {
+		c = exec.Command(interpreter, run)
+	} else {
+		c = exec.Command(run)
+	}
+	c.Dir = filepath.Dir(abs)
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, errgo.Notef(err, "session generator %q failed", path)
+	}
+	return out, nil
+}
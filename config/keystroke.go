@@ -0,0 +1,65 @@
+package config
+
+import (
+	"time"
+
+	"gopkg.in/errgo.v1"
+)
+
+// Keystroke is a step in typing into a window: a key to press or text to
+// type, or a pause. In YAML it is either a string, as given to tmux
+// send-keys, or a mapping with a sleep duration, or keys to type literally:
+//
+//	keystrokes: [ssh staging, Enter, {sleep: 2s}, hunter2, Enter]
+//	keystrokes: [{keys: "Enter your name:", literal: true}]
+type Keystroke struct {
+	// Keys is a key name, or text.
+	Keys string
+
+	// Literal types Keys as text, even if it's a key name.
+	Literal bool
+
+	// Sleep is how long to pause, for a prompt to appear say.
+	Sleep time.Duration
+}
+
+func (k Keystroke) MarshalYAML() (interface{}, error) {
+	if k.Sleep != 0 {
+		return map[string]string{"sleep": k.Sleep.String()}, nil
+	}
+	if k.Literal {
+		return map[string]interface{}{"keys": k.Keys, "literal": true}, nil
+	}
+	return k.Keys, nil
+}
+
+func (k *Keystroke) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		*k = Keystroke{Keys: str}
+		return nil
+	}
+
+	var fields struct {
+		Keys    string `yaml:"keys"`
+		Literal bool   `yaml:"literal"`
+		Sleep   string `yaml:"sleep"`
+	}
+	err := unmarshal(&fields)
+	if err != nil {
+		return err
+	}
+	if (fields.Keys == "") == (fields.Sleep == "") {
+		return errgo.New("keystroke must be keys, or a mapping with one of keys or a sleep")
+	}
+	if fields.Keys != "" {
+		*k = Keystroke{Keys: fields.Keys, Literal: fields.Literal}
+		return nil
+	}
+	d, err := time.ParseDuration(fields.Sleep)
+	if err != nil {
+		return errgo.Notef(err, "invalid keystroke sleep %q", fields.Sleep)
+	}
+	*k = Keystroke{Sleep: d}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func TestKeystrokeUnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		want    []config.Keystroke
+		wantErr bool
+	}{{
+		yaml: `[ssh staging, Enter]`,
+		want: []config.Keystroke{{Keys: "ssh staging"}, {Keys: "Enter"}},
+	}, {
+		yaml: `[{sleep: 2s}, {sleep: 500ms}]`,
+		want: []config.Keystroke{{Sleep: 2 * time.Second}, {Sleep: 500 * time.Millisecond}},
+	}, {
+		yaml: `[{keys: Enter, literal: true}]`,
+		want: []config.Keystroke{{Keys: "Enter", Literal: true}},
+	}, {
+		yaml:    `[{sleep: soon}]`,
+		wantErr: true,
+	}, {
+		yaml:    `[{keys: Enter, sleep: 1s}]`,
+		wantErr: true,
+	}, {
+		yaml:    `[{}]`,
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		var got []config.Keystroke
+		err := yaml.Unmarshal([]byte(test.yaml), &got)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.yaml, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.yaml, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.yaml, got, test.want)
+		}
+	}
+}
+
+func TestKeystrokeRoundTrip(t *testing.T) {
+	keystrokes := []config.Keystroke{
+		{Keys: "make"},
+		{Keys: "Enter", Literal: true},
+		{Sleep: time.Second},
+	}
+	out, err := yaml.Marshal(keystrokes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []config.Keystroke
+	err = yaml.Unmarshal(out, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, keystrokes) {
+		t.Errorf("got %+v, want %+v", got, keystrokes)
+	}
+}
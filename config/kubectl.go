@@ -0,0 +1,46 @@
+package config
+
+// Ways of opening a kubectl window onto pods.
+const (
+	KubectlLogs  = "logs"
+	KubectlExec  = "exec"
+	KubectlStern = "stern"
+)
+
+// Kubectl selects Kubernetes pods for a window to open onto, in place of
+// its command.
+type Kubectl struct {
+	// Context is the kubeconfig context, if not the current one.
+	Context string `yaml:"context,omitempty"`
+
+	// Namespace is the pods' namespace, if not the context's.
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Selector is a label selector matching the pods, such as app=api.
+	Selector string `yaml:"selector"`
+
+	// Container is the container in the pods, if not their default.
+	Container string `yaml:"container,omitempty"`
+
+	// Mode is how the pods are opened: KubectlLogs, following each pod's
+	// logs in a window of its own, which is the default; KubectlExec,
+	// running Command in each pod in a window of its own; or
+	// KubectlStern, following all their logs with stern in one window.
+	Mode string `yaml:"mode,omitempty"`
+
+	// Command is the command KubectlExec runs, by default sh.
+	Command string `yaml:"command,omitempty"`
+}
+
+// Args returns the arguments of a kubectl command on the pods' context and
+// namespace. Environment variables in them are expanded with expand.
+func (k *Kubectl) Args(expand func(string) string, args ...string) []string {
+	kubectlArgs := []string{"kubectl"}
+	if k.Context != "" {
+		kubectlArgs = append(kubectlArgs, "--context", expand(k.Context))
+	}
+	if k.Namespace != "" {
+		kubectlArgs = append(kubectlArgs, "--namespace", expand(k.Namespace))
+	}
+	return append(kubectlArgs, args...)
+}
@@ -0,0 +1,39 @@
+package config
+
+// Events that notifiers are told about.
+const (
+	EventSessionCreated = "session-created"
+	EventSessionKilled  = "session-killed"
+	EventSetupFinished  = "setup-finished"
+)
+
+// Notifier tells something outside tmuxg about events in a session's life,
+// by one of running a command, posting to a webhook, or messaging a Slack
+// incoming webhook.
+type Notifier struct {
+	// Events are the events to notify of, or all of them if empty.
+	Events []string `yaml:"events,omitempty"`
+
+	// Command is a shell command to run, with the event in
+	// $TMUXG_EVENT and the session's name in $TMUXG_SESSION.
+	Command string `yaml:"command,omitempty"`
+
+	// Webhook is a URL to post the event to, as JSON.
+	Webhook string `yaml:"webhook,omitempty"`
+
+	// Slack is the URL of a Slack incoming webhook to message.
+	Slack string `yaml:"slack,omitempty"`
+}
+
+// Notifies returns whether n notifies of event.
+func (n *Notifier) Notifies(event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package config
+
+import "time"
+
+// Ready checks that a window's command is ready, such as a server having
+// started listening, before tmuxg goes on to create the windows after it.
+// The check is one of TCP, File or Command, retried until it passes or
+// Timeout runs out.
+type Ready struct {
+	// TCP is a host:port that accepts connections once ready.
+	TCP string `yaml:"tcp,omitempty"`
+
+	// File is a path that exists once ready. It's relative to the
+	// window's working directory.
+	File string `yaml:"file,omitempty"`
+
+	// Command is a shell command that exits 0 once ready. It runs in
+	// the window's working directory.
+	Command string `yaml:"command,omitempty"`
+
+	// Timeout is how long to wait for the window to be ready, a minute
+	// if not set.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// DefaultReadyTimeout is how long to wait for a window to be ready, if its
+// check doesn't say.
+const DefaultReadyTimeout = time.Minute
+
+// DefaultWaitTimeout is how long to wait for a window's wait-for channel to
+// be signalled, if the window doesn't say.
+const DefaultWaitTimeout = 10 * time.Minute
@@ -0,0 +1,450 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// Session is the model of a tmux session, as declared in a session file.
+type Session struct {
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	SetupScript string            `yaml:"setup-script,omitempty"`
+	Setup       Setup             `yaml:"setup,omitempty"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Cwd         string            `yaml:"cwd,omitempty"`
+	Windows     []Window          `yaml:"windows"`
+	Focus       string            `yaml:"focus,omitempty"`
+	Socket      Socket            `yaml:"socket,omitempty"`
+	TmuxConfig  string            `yaml:"tmux-config,omitempty"`
+	Requires    Requirements      `yaml:"requires,omitempty"`
+	Hooks       Hooks             `yaml:"hooks,omitempty"`
+	Backend     string            `yaml:"backend,omitempty"`
+	Container   *Container        `yaml:"container,omitempty"`
+	Compose     *Compose          `yaml:"compose,omitempty"`
+	Vagrant     bool              `yaml:"vagrant,omitempty"`
+	Notify      []Notifier        `yaml:"notify,omitempty"`
+
+	// RequiresSessions are sessions, by name, that must be running
+	// before this one is built.
+	RequiresSessions []string `yaml:"requires-sessions,omitempty"`
+
+	// AfterAttach are tmux commands run whenever a client attaches to
+	// the session, such as display-message "welcome".
+	AfterAttach []string `yaml:"after-attach,omitempty"`
+
+	// RenumberWindows turns on tmux's renumber-windows option for the
+	// session, so that windows are renumbered to close gaps as they're
+	// closed.
+	RenumberWindows bool `yaml:"renumber-windows,omitempty"`
+
+	// Dir is the directory containing the session file.
+	Dir string `yaml:"-"`
+
+	// HostEnv, if set, holds environment variables of the host the
+	// session runs on, where they differ from tmuxg's.
+	HostEnv map[string]string `yaml:"-"`
+}
+
+// Window is a window in a session.
+type Window struct {
+	Name       string      `yaml:"name"`
+	Command    string      `yaml:"command,omitempty"`
+	Cwd        string      `yaml:"cwd,omitempty"`
+	Keystrokes []Keystroke `yaml:"keystrokes,omitempty"`
+
+	// Panes are split off the window, each running its own command,
+	// after the window's own pane.
+	Panes []Pane `yaml:"panes,omitempty"`
+
+	// Index, if set, pins the window to a tmux window index, which it's
+	// created at, and re-added at if closed.
+	Index *int `yaml:"index,omitempty"`
+
+	// SetupScript, if set, sets up the window's part of the project. It
+	// runs alongside the other windows' setup scripts, after the
+	// session's.
+	SetupScript string `yaml:"setup-script,omitempty"`
+
+	// Paste, if set, is pasted into the window, before its keystrokes
+	// are sent.
+	Paste string `yaml:"paste,omitempty"`
+
+	// KeystrokesTarget, if set, sends the window's keystrokes to another
+	// window of the session, by name, or to a tmux target, instead of to
+	// the window itself.
+	KeystrokesTarget string `yaml:"keystrokes-target,omitempty"`
+
+	// LinkWindow, if set, is a window of another session, as session:window,
+	// which is linked into this one instead of running a command. The
+	// sessions must share a tmux server.
+	LinkWindow string `yaml:"link-window,omitempty"`
+
+	// WaitFor, if set, is a tmux wait-for channel that a window before
+	// this one signals, which tmuxg waits for before creating it.
+	WaitFor string `yaml:"wait-for,omitempty"`
+
+	// WaitTimeout is how long to wait for WaitFor to be signalled,
+	// DefaultWaitTimeout if not set.
+	WaitTimeout time.Duration `yaml:"wait-timeout,omitempty"`
+
+	// DependsOn are windows, by name, that must be created, and be ready,
+	// before this one.
+	DependsOn []string `yaml:"depends-on,omitempty"`
+
+	// Ready, if set, is checked to pass before the windows after this
+	// one are created.
+	Ready *Ready `yaml:"ready,omitempty"`
+
+	// Log, if set, is a file that everything the window shows is
+	// appended to. It's relative to the window's working directory.
+	Log string `yaml:"log,omitempty"`
+
+	// Container, if set, runs the window's command in a container,
+	// instead of the session's container.
+	Container *Container `yaml:"container,omitempty"`
+
+	// VagrantSSH, if set, runs the window's command over vagrant ssh on
+	// the named machine.
+	VagrantSSH string `yaml:"vagrant-ssh,omitempty"`
+
+	// Worktrees, if set, repeats the window for git worktrees of the
+	// repository it starts in.
+	Worktrees *Worktrees `yaml:"worktrees,omitempty"`
+
+	// Kubectl, if set, opens the window onto Kubernetes pods instead of
+	// running its command.
+	Kubectl *Kubectl `yaml:"kubectl,omitempty"`
+
+	// When, if set, is a CEL expression, such as os == "linux", which
+	// must be true for the window to be created.
+	When string `yaml:"when,omitempty"`
+
+	// Count, if set, is a CEL expression for how many of the window to
+	// create. Each copy's index, from 0, is index in its expressions.
+	Count string `yaml:"count,omitempty"`
+
+	// Gate, if set, is a shell command that must succeed before the
+	// window's command runs, outside any container.
+	Gate string `yaml:"-"`
+}
+
+// Pane is a pane split off a window. Its working directory and command
+// default to the window's, and it runs in the window's container if the
+// window has one.
+type Pane struct {
+	// Title, if set, names the pane, so that it can be focused by name.
+	Title      string      `yaml:"title,omitempty"`
+	Command    string      `yaml:"command,omitempty"`
+	Cwd        string      `yaml:"cwd,omitempty"`
+	Keystrokes []Keystroke `yaml:"keystrokes,omitempty"`
+}
+
+// Setup controls how setup scripts are run.
+type Setup struct {
+	// Timeout, if set, is how long setup scripts may run before they're
+	// stopped, and the session fails to start.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Cwd, if set, is the directory the setup script runs in. It's
+	// relative to the session file. Otherwise the setup script runs in
+	// the session's cwd, or the directory it's in if it doesn't exist
+	// yet, or without a cwd, the session file's directory.
+	Cwd string `yaml:"cwd,omitempty"`
+
+	// InWindow runs the setup script in a window of the session, which
+	// can be watched, and the windows' setup scripts in their windows.
+	// Window commands start once setup has finished.
+	InWindow bool `yaml:"in-window,omitempty"`
+}
+
+// Hooks are shell commands run at points in a session's life, in the
+// session's working directory and environment.
+type Hooks struct {
+	// Start runs whenever tmuxg starts the session, whether or not it is
+	// already running.
+	Start string `yaml:"start,omitempty"`
+
+	// FirstStart runs when tmuxg creates the session, before its windows.
+	FirstStart string `yaml:"first-start,omitempty"`
+
+	// Restart runs when tmuxg starts a session that is already running.
+	Restart string `yaml:"restart,omitempty"`
+
+	// Broadcast is typed into the shell of every window, when tmuxg
+	// starts a session that is already running.
+	Broadcast string `yaml:"broadcast,omitempty"`
+
+	// Exit runs when tmuxg's client detaches from the session, or the
+	// session ends.
+	Exit string `yaml:"exit,omitempty"`
+
+	// Stop runs when the session is stopped with tmuxg stop.
+	Stop string `yaml:"stop,omitempty"`
+}
+
+// Requirements are what a session needs of the machine it runs on.
+type Requirements struct {
+	// Tmux is a constraint on the tmux version, such as ">=3.1". Several
+	// constraints may be separated by commas, as in ">=2.9, <3.3".
+	Tmux string `yaml:"tmux,omitempty"`
+}
+
+// Locate returns the path of a session file, given either its path or the
+// name of a session in the tmuxg config directory, or failing that, the
+// shared directory. If there's no such session, the error satisfies
+// os.IsNotExist.
+func Locate(name string) (string, error) {
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return name, nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return "", errgo.Notef(err, "failed to open session file %q", name)
+	}
+
+	tmuxgConfigDir := Dir()
+	err := os.MkdirAll(tmuxgConfigDir, 0644)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to create config directory %q", tmuxgConfigDir)
+	}
+
+	for _, dir := range []string{tmuxgConfigDir, SharedDir()} {
+		for _, ext := range SessionExts {
+			confPath := filepath.Join(dir, name+ext)
+			_, err = os.Stat(confPath)
+			if err == nil {
+				return confPath, nil
+			} else if !os.IsNotExist(err) {
+				return "", errgo.Notef(err, "failed to resolve session %q file %q", name, confPath)
+			}
+		}
+	}
+	return "", err
+}
+
+// Load reads a session file, or runs a Starlark session script or a
+// program that prints the session.
+func Load(confPath string) (*Session, error) {
+	return load(confPath, nil)
+}
+
+// LoadContents loads a session as Load does, but from contents already read
+// from the session file at confPath, so that a session file that was
+// checked before loading it is loaded as it was checked, even if it's
+// changed since.
+func LoadContents(confPath string, contents []byte) (*Session, error) {
+	if contents == nil {
+		contents = []byte{}
+	}
+	return load(confPath, contents)
+}
+
+// load loads the session file at confPath, or if contents is set, what was
+// read from it.
+func load(confPath string, src []byte) (*Session, error) {
+	var s Session
+
+	var contents []byte
+	var err error
+	switch {
+	case filepath.Ext(confPath) == StarlarkExt:
+		contents, err = evalStarlark(&s, confPath, src)
+	case isGenerator(confPath):
+		contents, err = runGenerator(confPath, src)
+	case src != nil:
+		contents = src
+	default:
+		contents, err = ioutil.ReadFile(confPath)
+	}
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read session file")
+	}
+	err = yaml.Unmarshal(contents, &s)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to parse session file")
+	}
+	// Follow links, so that a registered session file kept in a project
+	// finds things relative to the project.
+	if real, err := filepath.EvalSymlinks(confPath); err == nil {
+		confPath = real
+	}
+	s.Dir = filepath.Dir(confPath)
+
+	err = s.evalExpressions()
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to evaluate session file")
+	}
+
+	for i := range s.Windows {
+		if s.Windows[i].Command == "" {
+			s.Windows[i].Command = "bash"
+		}
+		for j := range s.Windows[i].Panes {
+			if s.Windows[i].Panes[j].Command == "" {
+				s.Windows[i].Panes[j].Command = "bash"
+			}
+		}
+	}
+	err = s.Validate()
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	return &s, nil
+}
+
+// SplitFocus splits a focus naming a pane, as window.pane, into the
+// window's name and the pane's index or title.
+func SplitFocus(focus string) (window, pane string) {
+	i := strings.LastIndex(focus, ".")
+	if i < 0 {
+		return focus, ""
+	}
+	return focus[:i], focus[i+1:]
+}
+
+// Validate checks that the session's windows have different names, as do
+// the panes of each, and that it focuses one of them.
+func (s *Session) Validate() error {
+	names := make(map[string]bool)
+	for _, w := range s.Windows {
+		if w.Name == "" {
+			continue
+		}
+		if names[w.Name] {
+			return errgo.Newf("more than one window is named %q", w.Name)
+		}
+		names[w.Name] = true
+		titles := make(map[string]bool)
+		for _, p := range w.Panes {
+			if p.Title == "" {
+				continue
+			}
+			if titles[p.Title] {
+				return errgo.Newf("more than one pane of window %q is titled %q", w.Name, p.Title)
+			}
+			titles[p.Title] = true
+		}
+	}
+	if s.Focus == "" || names[s.Focus] {
+		return nil
+	}
+	if window, pane := SplitFocus(s.Focus); names[window] {
+		for _, w := range s.Windows {
+			if w.Name == window && !w.hasPane(pane) {
+				return errgo.Newf("focus %q is not a pane of window %q", s.Focus, window)
+			}
+		}
+		return nil
+	}
+	if s.Compose != nil && (s.Focus == "logs" || strings.HasPrefix(s.Focus, "logs:")) {
+		return nil
+	}
+	for _, w := range s.Windows {
+		// Windows repeated for worktrees or pods are named after
+		// what they find.
+		if (w.Worktrees != nil || w.Kubectl != nil) && strings.HasPrefix(s.Focus, w.Name+"/") {
+			return nil
+		}
+	}
+	return errgo.Newf("focus %q is not the name of a window", s.Focus)
+}
+
+// hasPane returns whether the window has a pane with the index or title
+// pane, counting its own pane as 0.
+func (w *Window) hasPane(pane string) bool {
+	if i, err := strconv.Atoi(pane); err == nil {
+		return i >= 0 && i <= len(w.Panes)
+	}
+	for _, p := range w.Panes {
+		if p.Title == pane {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderWindows returns the windows in the order they should be created,
+// so that each comes after those it depends on. Otherwise windows keep
+// the order they're declared in.
+func OrderWindows(windows []Window) ([]Window, error) {
+	names := make(map[string]bool)
+	for _, w := range windows {
+		names[w.Name] = true
+	}
+	for _, w := range windows {
+		for _, dep := range w.DependsOn {
+			if !names[dep] {
+				return nil, errgo.Newf("window %q depends on unknown window %q", w.Name, dep)
+			}
+		}
+	}
+
+	created := make(map[string]bool)
+	done := make([]bool, len(windows))
+	var ordered []Window
+	for len(ordered) < len(windows) {
+		next := -1
+		for i, w := range windows {
+			if done[i] {
+				continue
+			}
+			ready := true
+			for _, dep := range w.DependsOn {
+				if !created[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, w := range windows {
+				if !done[i] {
+					cycle = append(cycle, w.Name)
+				}
+			}
+			return nil, errgo.Newf("windows %q depend on each other", cycle)
+		}
+		done[next] = true
+		created[windows[next].Name] = true
+		ordered = append(ordered, windows[next])
+	}
+	return ordered, nil
+}
+
+// ApplyConfig fills in settings the session leaves to the tmuxg config.
+func (s *Session) ApplyConfig(conf *Config) {
+	if s.Socket == (Socket{}) {
+		s.Socket = conf.Socket
+	}
+	if s.Backend == "" {
+		s.Backend = conf.Backend
+	}
+	s.Notify = append(append([]Notifier(nil), conf.Notify...), s.Notify...)
+}
+
+// ValidateLinks checks that a session that links windows from other
+// sessions runs on a shared server, as tmux only links windows between
+// sessions on the same server. It's checked once config.yaml's settings
+// are applied, as they may choose the server.
+func (s *Session) ValidateLinks() error {
+	if s.Socket.Shared() {
+		return nil
+	}
+	for _, w := range s.Windows {
+		if w.LinkWindow != "" {
+			return errgo.Newf("window %q links %q from another session, so the session needs a shared socket, such as default-server or a server's name", w.Name, w.LinkWindow)
+		}
+	}
+	return nil
+}
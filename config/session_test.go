@@ -0,0 +1,197 @@
+package config_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func TestOrderWindows(t *testing.T) {
+	tests := []struct {
+		about   string
+		windows []config.Window
+		want    []string
+		wantErr string
+	}{{
+		about:   "windows without dependencies keep their order",
+		windows: []config.Window{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+		want:    []string{"a", "b", "c"},
+	}, {
+		about: "windows come after those they depend on",
+		windows: []config.Window{
+			{Name: "web", DependsOn: []string{"db"}},
+			{Name: "shell"},
+			{Name: "db"},
+		},
+		want: []string{"shell", "db", "web"},
+	}, {
+		about: "dependencies are followed transitively",
+		windows: []config.Window{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c"},
+		},
+		want: []string{"c", "b", "a"},
+	}, {
+		about:   "unknown dependencies are an error",
+		windows: []config.Window{{Name: "a", DependsOn: []string{"nope"}}},
+		wantErr: `window "a" depends on unknown window "nope"`,
+	}, {
+		about: "cycles are an error",
+		windows: []config.Window{
+			{Name: "shell"},
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"a"}},
+		},
+		wantErr: `windows ["a" "b"] depend on each other`,
+	}}
+	for _, test := range tests {
+		t.Run(test.about, func(t *testing.T) {
+			ordered, err := config.OrderWindows(test.windows)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, w := range ordered {
+				names = append(names, w.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("got %q, want %q", names, test.want)
+			}
+		})
+	}
+}
+
+func TestContainerCommand(t *testing.T) {
+	expand := func(s string) string {
+		return strings.Replace(s, "$TAG", "1.22", -1)
+	}
+	env := map[string]string{"B": "2", "A": "it's"}
+	tests := []struct {
+		about     string
+		container config.Container
+		cwd       string
+		want      string
+	}{{
+		about:     "an image's container has the working directory mounted",
+		container: config.Container{Image: "golang:$TAG"},
+		cwd:       "/src",
+		want:      `'docker' 'run' '-it' '--rm' '-v' '/src:/src' '-w' '/src' '-e' 'A=it'\''s' '-e' 'B=2' 'golang:1.22' 'sh' '-c' 'go test'`,
+	}, {
+		about:     "a workdir is where the working directory is mounted",
+		container: config.Container{Image: "golang", Workdir: "/go/src"},
+		cwd:       "/src",
+		want:      `'docker' 'run' '-it' '--rm' '-v' '/src:/go/src' '-w' '/go/src' '-e' 'A=it'\''s' '-e' 'B=2' 'golang' 'sh' '-c' 'go test'`,
+	}, {
+		about:     "nothing is mounted without a working directory",
+		container: config.Container{Image: "golang"},
+		want:      `'docker' 'run' '-it' '--rm' '-e' 'A=it'\''s' '-e' 'B=2' 'golang' 'sh' '-c' 'go test'`,
+	}, {
+		about:     "a running container is executed in",
+		container: config.Container{Name: "dev"},
+		cwd:       "/src",
+		want:      `'docker' 'exec' '-it' '-w' '/src' '-e' 'A=it'\''s' '-e' 'B=2' 'dev' 'sh' '-c' 'go test'`,
+	}, {
+		about:     "a dev container defaults to its workspace folder",
+		container: config.Container{Devcontainer: "/src"},
+		cwd:       "/src/cmd",
+		want:      `'devcontainer' 'exec' '--workspace-folder' '/src' '--remote-env' 'A=it'\''s' '--remote-env' 'B=2' 'sh' '-c' 'go test'`,
+	}, {
+		about:     "a dev container's workdir is changed to",
+		container: config.Container{Devcontainer: "/src", Workdir: "/workspaces/src"},
+		cwd:       "/src",
+		want:      `'devcontainer' 'exec' '--workspace-folder' '/src' '--remote-env' 'A=it'\''s' '--remote-env' 'B=2' 'sh' '-c' 'cd '\''/workspaces/src'\'' && go test'`,
+	}}
+	for _, test := range tests {
+		t.Run(test.about, func(t *testing.T) {
+			got := test.container.Command("go test", test.cwd, env, expand)
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateFocus(t *testing.T) {
+	windows := []config.Window{{
+		Name:  "editor",
+		Panes: []config.Pane{{Title: "tests"}, {}},
+	}, {
+		Name: "shell",
+	}}
+	tests := []struct {
+		focus   string
+		wantErr string
+	}{
+		{focus: ""},
+		{focus: "shell"},
+		{focus: "editor.0"},
+		{focus: "editor.2"},
+		{focus: "editor.tests"},
+		{focus: "editor.3", wantErr: `focus "editor.3" is not a pane of window "editor"`},
+		{focus: "editor.logs", wantErr: `focus "editor.logs" is not a pane of window "editor"`},
+		{focus: "shell.1", wantErr: `focus "shell.1" is not a pane of window "shell"`},
+		{focus: "nope", wantErr: `focus "nope" is not the name of a window`},
+	}
+	for _, test := range tests {
+		s := &config.Session{Name: "dev", Focus: test.focus, Windows: windows}
+		err := s.Validate()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("focus %q: %v", test.focus, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("focus %q: got error %v, want %q", test.focus, err, test.wantErr)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"ls"}, `'ls'`},
+		{[]string{"echo", "it's"}, `'echo' 'it'\''s'`},
+		{[]string{""}, `''`},
+		{nil, ``},
+	}
+	for _, test := range tests {
+		if got := config.ShellQuote(test.words...); got != test.want {
+			t.Errorf("ShellQuote(%q): got %s, want %s", test.words, got, test.want)
+		}
+	}
+}
+
+func TestValidateLinks(t *testing.T) {
+	windows := []config.Window{{Name: "editor"}, {Name: "dashboard", LinkWindow: "monitoring:dashboard"}}
+	tests := []struct {
+		socket  config.Socket
+		wantErr bool
+	}{
+		{socket: config.Socket{}, wantErr: true},
+		{socket: config.Socket{Strategy: config.SocketPerSession}, wantErr: true},
+		{socket: config.Socket{Strategy: config.SocketDefaultServer}},
+		{socket: config.Socket{Name: "work"}},
+		{socket: config.Socket{Path: "/tmp/work.sock"}},
+	}
+	for _, test := range tests {
+		s := &config.Session{Name: "dev", Socket: test.socket, Windows: windows}
+		err := s.ValidateLinks()
+		if test.wantErr && err == nil {
+			t.Errorf("socket %+v: want an error", test.socket)
+		} else if !test.wantErr && err != nil {
+			t.Errorf("socket %+v: %v", test.socket, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package config
+
+import (
+	"strings"
+)
+
+// ShellQuote quotes words for the shell, each as a single word, and joins
+// them with spaces, as a command line.
+func ShellQuote(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"gopkg.in/errgo.v1"
+)
+
+// Socket strategies, selecting which tmux server a session runs on.
+const (
+	SocketDefaultServer = "default-server"
+	SocketPerSession    = "per-session"
+)
+
+// Socket selects the tmux server a session runs on. In YAML it is either a
+// strategy, the name of a shared server, or a mapping with one of name or
+// path:
+//
+//	socket: default-server
+//	socket: work
+//	socket: {name: work}
+//	socket: {path: /run/user/1000/proj.sock}
+type Socket struct {
+	// Strategy is SocketDefaultServer or SocketPerSession, or empty if
+	// Name or Path is set. The zero Socket is per-session.
+	Strategy string `yaml:"-"`
+
+	// Name is the name of a shared server (tmux -L).
+	Name string `yaml:"name,omitempty"`
+
+	// Path is the path of a server's socket (tmux -S). It may use
+	// environment variables.
+	Path string `yaml:"path,omitempty"`
+}
+
+// Shared returns whether sessions on the socket's server can share it with
+// other sessions, as a per-session server can't.
+func (s Socket) Shared() bool {
+	return s.Strategy == SocketDefaultServer || s.Name != "" || s.Path != ""
+}
+
+func (s Socket) MarshalYAML() (interface{}, error) {
+	switch {
+	case s.Strategy != "":
+		return s.Strategy, nil
+	case s.Path != "":
+		return map[string]string{"path": s.Path}, nil
+	case s.Name != "":
+		return s.Name, nil
+	}
+	return nil, nil
+}
+
+func (s *Socket) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err == nil {
+		switch str {
+		case SocketDefaultServer, SocketPerSession:
+			*s = Socket{Strategy: str}
+		default:
+			*s = Socket{Name: str}
+		}
+		return nil
+	}
+
+	var fields struct {
+		Name string `yaml:"name"`
+		Path string `yaml:"path"`
+	}
+	err := unmarshal(&fields)
+	if err != nil {
+		return err
+	}
+	if fields.Name != "" && fields.Path != "" {
+		return errgo.New("socket may have a name or a path, not both")
+	}
+	*s = Socket{Name: fields.Name, Path: fields.Path}
+	return nil
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// StarlarkExt is the extension of session files written in Starlark, which
+// compute a session rather than declare it. The script sets the global
+// session to a dict of what a session file would hold.
+const StarlarkExt = ".star"
+
+// evalStarlark runs the Starlark session script at path, or src if it's
+// set, and returns the session it computes as YAML, to be loaded as a
+// session file is. The script sees the environment as s does.
+func evalStarlark(s *Session, path string, src []byte) ([]byte, error) {
+	predeclared := starlark.StringDict{
+		"json":      json.Module,
+		"getenv":    starlark.NewBuiltin("getenv", starlarkGetenv),
+		"glob":      starlark.NewBuiltin("glob", starlarkGlob),
+		"read_file": starlark.NewBuiltin("read_file", starlarkReadFile),
+		"run":       starlark.NewBuiltin("run", starlarkRun),
+	}
+	thread := &starlark.Thread{Name: path}
+	thread.SetLocal(starlarkDirKey, filepath.Dir(path))
+	thread.SetLocal(starlarkSessionKey, s)
+	var source interface{}
+	if src != nil {
+		source = src
+	}
+	globals, err := starlark.ExecFile(thread, path, source, predeclared)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to run session script")
+	}
+	session, ok := globals["session"]
+	if !ok {
+		return nil, errgo.Newf("session script %q doesn't set session", path)
+	}
+	v, err := fromStarlark(session)
+	if err != nil {
+		return nil, errgo.Notef(err, "invalid session")
+	}
+	return yaml.Marshal(v)
+}
+
+// fromStarlark converts a Starlark value into the Go value YAML would
+// unmarshal it as.
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, errgo.Newf("%v is too big", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.Dict:
+		m := make(map[string]interface{})
+		for _, item := range v.Items() {
+			k, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, errgo.Newf("key %v is not a string", item[0])
+			}
+			val, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, errgo.Notef(err, "%s", k)
+			}
+			m[string(k)] = val
+		}
+		return m, nil
+	case starlark.Indexable:
+		var l []interface{}
+		for i := 0; i < v.Len(); i++ {
+			val, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, errgo.Mask(err)
+			}
+			l = append(l, val)
+		}
+		return l, nil
+	}
+	return nil, errgo.Newf("%s %v can't be part of a session", v.Type(), v)
+}
+
+// starlarkDirKey is the thread local holding the session script's
+// directory, which paths and commands in it are relative to.
+const starlarkDirKey = "dir"
+
+// starlarkSessionKey is the thread local holding the session whose
+// environment the session script sees.
+const starlarkSessionKey = "session"
+
+// starlarkPath returns path relative to the session script's directory.
+func starlarkPath(thread *starlark.Thread, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(thread.Local(starlarkDirKey).(string), path)
+}
+
+// starlarkGetenv is getenv(name, default=""), which returns the value of
+// an environment variable, as the session sees it.
+func starlarkGetenv(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name, def string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "default?", &def)
+	if err != nil {
+		return nil, err
+	}
+	s := thread.Local(starlarkSessionKey).(*Session)
+	if v, ok := s.LookupEnv(name); ok {
+		return starlark.String(v), nil
+	}
+	return starlark.String(def), nil
+}
+
+// starlarkGlob is glob(pattern), which returns the sorted paths matching
+// pattern.
+func starlarkGlob(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "pattern", &pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(starlarkPath(thread, pattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var paths []starlark.Value
+	for _, m := range matches {
+		paths = append(paths, starlark.String(m))
+	}
+	return starlark.NewList(paths), nil
+}
+
+// starlarkReadFile is read_file(path), which returns a file's contents.
+func starlarkReadFile(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "path", &path)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := ioutil.ReadFile(starlarkPath(thread, path))
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(contents), nil
+}
+
+// starlarkRun is run(command), which runs a shell command and returns what
+// it prints, failing if the command does.
+func starlarkRun(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var command string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "command", &command)
+	if err != nil {
+		return nil, err
+	}
+	c := exec.Command("sh", "-c", command)
+	c.Dir = starlarkPath(thread, ".")
+	c.Env = thread.Local(starlarkSessionKey).(*Session).Env()
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, errgo.Notef(err, "%q failed", command)
+	}
+	return starlark.String(out), nil
+}
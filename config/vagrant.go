@@ -0,0 +1,13 @@
+package config
+
+// VagrantCommand returns a shell command running command over vagrant ssh
+// on machine, or the default machine if it's empty.
+func VagrantCommand(machine, command string) string {
+	args := []string{"vagrant", "ssh"}
+	if machine != "" {
+		args = append(args, machine)
+	}
+	// Interactive commands need a terminal, which vagrant ssh -c doesn't
+	// give them otherwise.
+	return ShellQuote(append(args, "-c", command, "--", "-t")...)
+}
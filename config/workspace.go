@@ -0,0 +1,66 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+)
+
+// Workspace is a set of sessions started together, such as the frontend,
+// backend and infrastructure sessions of one product.
+type Workspace struct {
+	Name string `yaml:"name"`
+
+	// Sessions are the names or paths of the session files of the
+	// workspace's sessions, started in order.
+	Sessions []string `yaml:"sessions"`
+
+	// Attach is the session attached to once they've all started, by
+	// default the first.
+	Attach string `yaml:"attach,omitempty"`
+}
+
+// LocateWorkspace returns the path of a workspace file, given either its
+// path or the name of a workspace in the workspaces directory of the tmuxg
+// config directory. If there's no such workspace, the error satisfies
+// os.IsNotExist.
+func LocateWorkspace(name string) (string, error) {
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return name, nil
+	}
+	path := filepath.Join(Dir(), "workspaces", name+".yaml")
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// LoadWorkspace reads a workspace file.
+func LoadWorkspace(path string) (*Workspace, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read workspace file")
+	}
+	var ws Workspace
+	err = yaml.Unmarshal(contents, &ws)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to parse workspace file")
+	}
+	if len(ws.Sessions) == 0 {
+		return nil, errgo.Newf("workspace %q has no sessions", ws.Name)
+	}
+	if ws.Attach == "" {
+		ws.Attach = ws.Sessions[0]
+	}
+	found := false
+	for _, s := range ws.Sessions {
+		found = found || s == ws.Attach
+	}
+	if !found {
+		return nil, errgo.Newf("workspace %q attaches to %q, which isn't one of its sessions", ws.Name, ws.Attach)
+	}
+	return &ws, nil
+}
@@ -0,0 +1,15 @@
+package config
+
+// Worktrees repeats a window for each git worktree of the repository it
+// starts in.
+type Worktrees struct {
+	// Branches, if set, are the branches to open windows onto, each in a
+	// worktree of its own, which is added if there isn't one yet.
+	// Otherwise the window is repeated for every existing worktree.
+	Branches []string `yaml:"branches,omitempty"`
+
+	// Dir is where worktrees are added, in a directory named after the
+	// branch. By default they're added next to the repository, named
+	// after it and the branch.
+	Dir string `yaml:"dir,omitempty"`
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// projectMarkers are files that identify the kind of project a directory
+// holds, and the template suited to each, in order of preference.
+var projectMarkers = []struct {
+	file, template string
+}{
+	{"go.mod", "go"},
+	{"Cargo.toml", "rust"},
+	{"package.json", "node"},
+	{"pyproject.toml", "python"},
+	{"setup.py", "python"},
+	{"requirements.txt", "python"},
+}
+
+// detectProject looks for the git repository containing dir, and returns
+// its root and the template suited to the kind of project it holds. Outside
+// a repository, root is empty and the template is the traditional go one.
+func detectProject(dir string) (root, template string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			root = d
+			break
+		}
+		if filepath.Dir(d) == d {
+			return "", "go"
+		}
+	}
+	// Check the directory itself before the root, to find the right kind
+	// of project in a monorepo.
+	for _, d := range []string{dir, root} {
+		for _, m := range projectMarkers {
+			if _, err := os.Stat(filepath.Join(d, m.file)); err == nil {
+				return root, m.template
+			}
+		}
+	}
+	return root, "generic"
+}
+
+// devcontainerHint is added to session files created in a project with a
+// dev container, given the project's root.
+const devcontainerHint = `
+# This project has a dev container. Uncomment to run the windows in it with
+# devcontainer exec, as VS Code does.
+#container:
+#  devcontainer: %s
+`
+
+// hasDevcontainer returns whether the project at root has a dev container.
+func hasDevcontainer(root string) bool {
+	if root == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(root, ".devcontainer", "devcontainer.json"))
+	return err == nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/tmux"
+)
+
+var errorFormatFlag = flag.String("error-format", "text", "how to report a failure: text or json")
+
+// Causes of failure that tmuxg reports through its exit status, so wrapper
+// scripts can tell them apart.
+var (
+	errUsage          = errgo.New("usage error")
+	errConfigNotFound = errgo.New("session config not found")
+	errParse          = errgo.New("invalid session config")
+	errTmuxMissing    = errgo.New("tmux not found")
+	errSetupFailed    = errgo.New("setup script failed")
+	errAttachFailed   = errgo.New("failed to attach to session")
+	errSessionEnded   = errgo.New("session ended")
+	errUntrusted      = errgo.New("session config not trusted")
+)
+
+type failure struct {
+	code int
+	kind string
+}
+
+var failures = map[error]failure{
+	errUsage:          {2, "usage"},
+	errConfigNotFound: {3, "config-not-found"},
+	errParse:          {4, "parse-error"},
+	errTmuxMissing:    {5, "tmux-missing"},
+	errSetupFailed:    {6, "setup-failed"},
+	errAttachFailed:   {7, "attach-failed"},
+	tmux.ErrNested:    {8, "nested"},
+	tmux.ErrVersion:   {9, "tmux-version"},
+	errSessionEnded:   {10, "session-ended"},
+	errUntrusted:      {11, "untrusted"},
+}
+
+// pluginExit is the cause of a plugin's failure: tmuxg exits with the
+// plugin's status.
+type pluginExit int
+
+func (e pluginExit) Error() string {
+	return fmt.Sprintf("plugin exited with status %d", int(e))
+}
+
+// failureOf returns how err should be reported to the caller.
+func failureOf(err error) failure {
+	cause := errgo.Cause(err)
+	if code, ok := cause.(pluginExit); ok {
+		return failure{int(code), "plugin"}
+	}
+	if f, ok := failures[cause]; ok {
+		return f
+	}
+	return failure{1, "error"}
+}
+
+func reportError(w io.Writer, err error) {
+	f := failureOf(err)
+	if *errorFormatFlag == "json" {
+		json.NewEncoder(w).Encode(struct {
+			Error   string `json:"error"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{
+			Error:   f.kind,
+			Code:    f.code,
+			Message: err.Error(),
+		})
+		return
+	}
+	if f.kind == "plugin" {
+		// The plugin has said why it failed.
+		return
+	}
+	fmt.Fprintln(w, errgo.Details(err))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// exporter converts a tmuxg session into another tool's config, and
+// reports anything about it that couldn't be converted.
+type exporter func(s *config.Session) (out []byte, unmapped []string, err error)
+
+var exporters = map[string]exporter{
+	"tmuxinator": exportTmuxinator,
+}
+
+// exportCommand writes a session in another tool's format to standard
+// output.
+func exportCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "format to export to")
+	args, err := parseCommandFlags("export", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("export", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	export, ok := exporters[*format]
+	if !ok {
+		var names []string
+		for name := range exporters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errgo.WithCausef(nil, errUsage, "cannot export to %q, only to %s", *format, strings.Join(names, ", "))
+	}
+
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	out, unmapped, err := export(s)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	_, err = os.Stdout.Write(out)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, u := range unmapped {
+		fmt.Fprintf(os.Stderr, "not exported: %s\n", u)
+	}
+	return nil
+}
+
+// exportTmuxinator converts a session into a tmuxinator project.
+func exportTmuxinator(s *config.Session) ([]byte, []string, error) {
+	var unmapped []string
+	project := yaml.MapSlice{{Key: "name", Value: s.Name}}
+	if s.Cwd != "" {
+		project = append(project, yaml.MapItem{Key: "root", Value: s.ExpandEnv(s.Cwd)})
+	}
+	if s.Socket.Name != "" {
+		project = append(project, yaml.MapItem{Key: "socket_name", Value: s.Socket.Name})
+	} else if s.Socket != (config.Socket{}) {
+		unmapped = append(unmapped, "socket")
+	}
+	hooks := []struct {
+		key, script string
+	}{
+		{"on_project_start", s.Hooks.Start},
+		// tmuxinator has no setup script, but the first start comes
+		// closest.
+		{"on_project_first_start", strings.TrimSpace(s.SetupScript + "\n" + s.Hooks.FirstStart)},
+		{"on_project_restart", s.Hooks.Restart},
+		{"on_project_exit", s.Hooks.Exit},
+		{"on_project_stop", s.Hooks.Stop},
+	}
+	for _, hook := range hooks {
+		if hook.script != "" {
+			project = append(project, yaml.MapItem{Key: hook.key, Value: hook.script})
+		}
+	}
+	if len(s.Environment) > 0 {
+		// tmuxinator has no environment, but can set it in every
+		// window. Values are expanded here, as the session's cwd is,
+		// since they're quoted.
+		var names []string
+		for name := range s.Environment {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var exports []string
+		for _, name := range names {
+			exports = append(exports, "export "+name+"="+config.ShellQuote(s.Getenv(name)))
+		}
+		project = append(project, yaml.MapItem{Key: "pre_window", Value: strings.Join(exports, "; ")})
+	}
+	if s.Focus != "" {
+		project = append(project, yaml.MapItem{Key: "startup_window", Value: s.Focus})
+	}
+	if s.TmuxConfig != "" {
+		project = append(project, yaml.MapItem{Key: "tmux_options", Value: "-f " + s.TmuxConfig})
+	}
+	if s.Requires != (config.Requirements{}) {
+		unmapped = append(unmapped, "requires")
+	}
+
+	var windows []interface{}
+	for _, w := range s.Windows {
+		var window interface{} = w.Command
+		if w.Cwd != "" || len(w.Panes) > 0 {
+			panes := []interface{}{w.Command}
+			for j, p := range w.Panes {
+				if p.Title != "" {
+					panes = append(panes, yaml.MapSlice{{Key: p.Title, Value: p.Command}})
+				} else {
+					panes = append(panes, p.Command)
+				}
+				if p.Cwd != "" {
+					unmapped = append(unmapped, fmt.Sprintf("cwd of pane %d of window %q", j+1, w.Name))
+				}
+				if len(p.Keystrokes) > 0 {
+					unmapped = append(unmapped, fmt.Sprintf("keystrokes of pane %d of window %q", j+1, w.Name))
+				}
+			}
+			var opts yaml.MapSlice
+			if w.Cwd != "" {
+				opts = append(opts, yaml.MapItem{Key: "root", Value: s.ExpandEnv(w.Cwd)})
+			}
+			window = append(opts, yaml.MapItem{Key: "panes", Value: panes})
+		}
+		windows = append(windows, yaml.MapSlice{{Key: w.Name, Value: window}})
+		if len(w.Keystrokes) > 0 {
+			unmapped = append(unmapped, fmt.Sprintf("keystrokes of window %q", w.Name))
+		}
+	}
+	project = append(project, yaml.MapItem{Key: "windows", Value: windows})
+
+	out, err := yaml.Marshal(project)
+	return out, unmapped, errgo.Mask(err)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/errgo.v1"
+)
+
+// cacheDir is where tmuxg keeps things fetched from elsewhere.
+func cacheDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(dir, "tmuxg")
+}
+
+// isRemoteTemplate returns whether a template name refers to a template
+// elsewhere, rather than one in the template directory or built in. Remote
+// templates are either a file in a git repository, given as
+// <repository>//<path>, or an HTTP(S) URL.
+func isRemoteTemplate(name string) bool {
+	return strings.Contains(name, "/")
+}
+
+// fetchTemplate fetches a remote template into the cache, and returns the
+// path of the cached copy. If fetching fails but a previously cached copy is
+// available, that is used instead.
+func fetchTemplate(name string) (string, error) {
+	scheme := ""
+	rest := name
+	if i := strings.Index(name, "://"); i >= 0 {
+		scheme, rest = name[:i+3], name[i+3:]
+	}
+	if i := strings.Index(rest, "//"); i >= 0 {
+		repo, path := scheme+rest[:i], rest[i+2:]
+		if scheme == "" && !strings.Contains(repo, "@") {
+			repo = "https://" + repo
+		}
+		if !strings.HasSuffix(path, ".yaml") {
+			path += ".yaml"
+		}
+		dir, err := fetchRepo(repo)
+		if err != nil {
+			return "", errgo.Mask(err)
+		}
+		return filepath.Join(dir, filepath.FromSlash(path)), nil
+	}
+	if scheme != "http://" && scheme != "https://" {
+		return "", errgo.Newf("template %q is not a <repository>//<path> or an http(s) URL", name)
+	}
+	return fetchURL(name)
+}
+
+// cachePath returns where to cache something fetched from src.
+func cachePath(kind, src string) string {
+	return filepath.Join(cacheDir(), kind, fmt.Sprintf("%x", sha256.Sum256([]byte(src))))
+}
+
+// fetchTimeout is how long tmuxg waits for git to clone or update a
+// repository before giving up on it, and using the cached copy, if any.
+const fetchTimeout = 2 * time.Minute
+
+// fetchRepo clones or updates a git repository in the cache, and returns
+// the path to the clone.
+func fetchRepo(repo string) (string, error) {
+	dir := cachePath("repos", repo)
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	var c *exec.Cmd
+	cloning := false
+	if _, err := os.Stat(dir); err == nil {
+		c = exec.CommandContext(ctx, "git", "-C", dir, "pull", "--ff-only", "--quiet")
+	} else {
+		err = os.MkdirAll(filepath.Dir(dir), 0755)
+		if err != nil {
+			return "", errgo.Notef(err, "failed to create cache directory")
+		}
+		c = exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth", "1", repo, dir)
+		cloning = true
+	}
+	// Fail rather than wait on a prompt for credentials.
+	c.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	log.Printf("%v", c)
+	err := c.Run()
+	if ctx.Err() != nil {
+		err = errgo.Notef(ctx.Err(), "gave up after %v", fetchTimeout)
+	}
+	if err != nil {
+		if cloning {
+			// Don't leave half a clone to be taken for a cached copy.
+			os.RemoveAll(dir)
+		} else if _, statErr := os.Stat(filepath.Join(dir, ".git")); statErr == nil {
+			log.Printf("failed to update %q, using cached copy: %v", repo, err)
+			return dir, nil
+		}
+		return "", errgo.Notef(err, "failed to fetch %q", repo)
+	}
+	return dir, nil
+}
+
+// fetchURL downloads a file into the cache, and returns the path to it.
+func fetchURL(url string) (string, error) {
+	path := cachePath("urls", url) + ".yaml"
+	err := download(url, path)
+	if err != nil {
+		if _, statErr := os.Stat(path); statErr == nil {
+			log.Printf("failed to fetch %q, using cached copy: %v", url, err)
+			return path, nil
+		}
+		return "", errgo.Mask(err)
+	}
+	return path, nil
+}
+
+// httpClient is what tmuxg fetches things over HTTP with, giving up on
+// servers that don't answer in time.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// download fetches url to path, replacing whatever is there only once it's
+// all been fetched.
+func download(url, path string) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return errgo.Notef(err, "failed to fetch %q", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errgo.Newf("failed to fetch %q: %s", url, resp.Status)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create cache directory")
+	}
+	// Write to a temporary file first, so a failed download doesn't
+	// clobber a good cached copy.
+	f, err := os.CreateTemp(filepath.Dir(path), "download")
+	if err != nil {
+		return errgo.Notef(err, "failed to create cache file")
+	}
+	defer os.Remove(f.Name())
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errgo.Notef(err, "failed to fetch %q", url)
+	}
+	return errgo.Mask(os.Rename(f.Name(), path))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// runHook runs one of the session's hooks, if it's set.
+func runHook(s *config.Session, name, script string) error {
+	if script == "" {
+		return nil
+	}
+	c := exec.Command("/bin/sh", "-c", script)
+	c.Env = s.Env()
+	if dir := s.ExpandEnv(s.Cwd); dir != "" {
+		if _, err := os.Stat(dir); err == nil {
+			c.Dir = dir
+		}
+	}
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("running %s hook", name)
+	err := c.Run()
+	if err != nil {
+		return errgo.Notef(err, "%s hook failed", name)
+	}
+	return nil
+}
+
+// stopCommand runs a session's stop hook, and kills it.
+func stopCommand(conf *config.Config, args []string) error {
+	err := commandArgs("stop", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	return errgo.Mask(stopSession(conf, s), errgo.Any)
+}
+
+// stopSession runs a session's stop hook, and kills it.
+func stopSession(conf *config.Config, s *config.Session) error {
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if !backend.Running(b) {
+		return errgo.Newf("session %q is not running", s.Name)
+	}
+	err = runHook(s, "stop", s.Hooks.Stop)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = b.Kill()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	notifyEvent(s, config.EventSessionKilled)
+	return errgo.Mask(composeDown(s))
+}
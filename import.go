@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// importer converts another tool's session configs into tmuxg sessions.
+type importer struct {
+	// paths returns the config files to import when none are given.
+	paths func() ([]string, error)
+
+	// convert converts a config file, and reports anything about it that
+	// couldn't be converted.
+	convert func(path string, contents []byte) (*config.Session, []string, error)
+}
+
+var importers = map[string]importer{
+	"smug":       {smugPaths, convertSmug},
+	"teamocil":   {teamocilPaths, convertTeamocil},
+	"tmuxinator": {tmuxinatorPaths, convertTmuxinator},
+	"tmuxp":      {tmuxpPaths, convertTmuxp},
+}
+
+// importCommand converts other tools' configs into tmuxg session files.
+func importCommand(conf *config.Config, args []string) error {
+	if len(args) < 1 {
+		return errgo.Mask(commandArgs("import", args, 1), errgo.Any)
+	}
+	imp, ok := importers[args[0]]
+	if !ok {
+		var names []string
+		for name := range importers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errgo.WithCausef(nil, errUsage, "cannot import from %q, only from %s", args[0], strings.Join(names, ", "))
+	}
+	paths := args[1:]
+	if len(paths) == 0 {
+		var err error
+		paths, err = imp.paths()
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if len(paths) == 0 {
+			return errgo.Newf("no %s configs found", args[0])
+		}
+	}
+
+	var failed int
+	for _, path := range paths {
+		err := importFile(imp, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errgo.Newf("failed to import %d of %d configs", failed, len(paths))
+	}
+	return nil
+}
+
+// importFile converts a config file into a session file in the config
+// directory, which must not already exist.
+func importFile(imp importer, path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	s, unmapped, err := imp.convert(path, contents)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if len(s.Windows) == 0 {
+		return errgo.New("no windows to import")
+	}
+
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = os.MkdirAll(config.Dir(), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create config directory")
+	}
+	confPath := filepath.Join(config.Dir(), s.Name+".yaml")
+	f, err := os.OpenFile(confPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return errgo.Newf("session %q already exists in %q", s.Name, confPath)
+	} else if err != nil {
+		return errgo.Mask(err)
+	}
+	_, err = f.Write(out)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errgo.Notef(err, "failed to write %q", confPath)
+	}
+
+	fmt.Printf("%s: imported as %s\n", path, confPath)
+	for _, u := range unmapped {
+		fmt.Printf("%s: not imported: %s\n", path, u)
+	}
+	return nil
+}
+
+// globConfigs returns the config files matching patterns, in order.
+func globConfigs(patterns ...string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errgo.Mask(err)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// homeDir returns the user's home directory.
+func homeDir() string {
+	return os.Getenv("HOME")
+}
+
+// expandHome turns a leading ~ in a path into $HOME, which tmuxg expands.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return "${HOME}" + path[1:]
+	}
+	return path
+}
+
+// yamlString returns v as a string, if it is a scalar.
+func yamlString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", true
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// yamlStrings returns v as a list of strings, if it is a scalar or a list of
+// scalars.
+func yamlStrings(v interface{}) ([]string, bool) {
+	if s, ok := yamlString(v); ok {
+		if s == "" {
+			return nil, true
+		}
+		return []string{s}, true
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	var ss []string
+	for _, item := range list {
+		s, ok := yamlString(item)
+		if !ok {
+			return nil, false
+		}
+		ss = append(ss, s)
+	}
+	return ss, true
+}
+
+// yamlMap returns v as a map with string keys, if it is a mapping.
+func yamlMap(v interface{}) (map[string]interface{}, bool) {
+	if sm, ok := v.(map[string]interface{}); ok {
+		// As JSON decodes.
+		return sm, true
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, false
+	}
+	sm := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		sm[fmt.Sprint(k)] = v
+	}
+	return sm, true
+}
+
+// sortedKeys returns the keys of m in order, for stable reports.
+func sortedKeys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
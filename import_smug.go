@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func smugPaths() ([]string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(homeDir(), ".config")
+	}
+	return globConfigs(filepath.Join(dir, "smug", "*.yml"), filepath.Join(dir, "smug", "*.yaml"))
+}
+
+// convertSmug converts a smug project.
+func convertSmug(file string, contents []byte) (*config.Session, []string, error) {
+	var raw interface{}
+	err := yaml.Unmarshal(contents, &raw)
+	if err != nil {
+		return nil, nil, errgo.Notef(err, "failed to parse")
+	}
+	project, ok := yamlMap(raw)
+	if !ok {
+		return nil, nil, errgo.New("not a smug project")
+	}
+
+	var s config.Session
+	var unmapped []string
+	for _, key := range sortedKeys(project) {
+		v := project[key]
+		switch key {
+		case "session":
+			s.Name, _ = yamlString(v)
+		case "root":
+			root, _ := yamlString(v)
+			s.Cwd = expandHome(root)
+		case "env":
+			env, _ := yamlMap(v)
+			s.Environment = map[string]string{}
+			for k, v := range env {
+				s.Environment[k], _ = yamlString(v)
+			}
+		case "before_start", "beforeStart":
+			s.Hooks.FirstStart, _ = hookScript(v)
+		case "stop":
+			s.Hooks.Stop, _ = hookScript(v)
+		case "windows":
+		default:
+			unmapped = append(unmapped, key)
+		}
+	}
+
+	windows, _ := project["windows"].([]interface{})
+	for i, item := range windows {
+		opts, ok := yamlMap(item)
+		if !ok {
+			unmapped = append(unmapped, fmt.Sprintf("window %d, which isn't in a form tmuxg understands", i))
+			continue
+		}
+		w := config.Window{Name: fmt.Sprintf("window%d", i)}
+		if name, ok := yamlString(opts["name"]); ok && name != "" {
+			w.Name = name
+		}
+		if manual, _ := opts["manual"].(bool); manual {
+			unmapped = append(unmapped, fmt.Sprintf("window %q, which is only started on request", w.Name))
+			continue
+		}
+		for _, key := range sortedKeys(opts) {
+			v := opts[key]
+			switch key {
+			case "name", "manual":
+			case "root":
+				// Relative to the project root.
+				root, _ := yamlString(v)
+				root = expandHome(root)
+				if !path.IsAbs(root) && !strings.HasPrefix(root, "$") {
+					root = path.Join(s.Cwd, root)
+				}
+				w.Cwd = root
+			case "commands":
+				cmds, _ := yamlStrings(v)
+				w.Command = shellCommand(cmds)
+			case "panes":
+				panes, _ := v.([]interface{})
+				for j, item := range panes {
+					pane, ok := yamlMap(item)
+					if !ok {
+						unmapped = append(unmapped, fmt.Sprintf("pane %d of window %q, which isn't in a form tmuxg understands", j+1, w.Name))
+						continue
+					}
+					var p config.Pane
+					for _, key := range sortedKeys(pane) {
+						switch key {
+						case "root":
+							root, _ := yamlString(pane[key])
+							root = expandHome(root)
+							if !path.IsAbs(root) && !strings.HasPrefix(root, "$") {
+								root = path.Join(s.Cwd, root)
+							}
+							p.Cwd = root
+						case "commands":
+							cmds, _ := yamlStrings(pane[key])
+							p.Command = shellCommand(cmds)
+						default:
+							unmapped = append(unmapped, fmt.Sprintf("%s of pane %d of window %q", key, j+1, w.Name))
+						}
+					}
+					w.Panes = append(w.Panes, p)
+				}
+			default:
+				unmapped = append(unmapped, fmt.Sprintf("%s of window %q", key, w.Name))
+			}
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	return &s, unmapped, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func teamocilPaths() ([]string, error) {
+	return globConfigs(filepath.Join(homeDir(), ".teamocil", "*.yml"))
+}
+
+// convertTeamocil converts a teamocil layout, in either the current format
+// or the older one wrapped in a session, with splits instead of panes.
+func convertTeamocil(path string, contents []byte) (*config.Session, []string, error) {
+	var raw interface{}
+	err := yaml.Unmarshal(contents, &raw)
+	if err != nil {
+		return nil, nil, errgo.Notef(err, "failed to parse")
+	}
+	layout, ok := yamlMap(raw)
+	if !ok {
+		return nil, nil, errgo.New("not a teamocil layout")
+	}
+	if session, ok := yamlMap(layout["session"]); ok {
+		layout = session
+	}
+
+	var s config.Session
+	var unmapped []string
+	for _, key := range sortedKeys(layout) {
+		switch key {
+		case "name":
+			s.Name, _ = yamlString(layout[key])
+		case "windows":
+		default:
+			unmapped = append(unmapped, key)
+		}
+	}
+
+	windows, _ := layout["windows"].([]interface{})
+	for i, item := range windows {
+		opts, ok := yamlMap(item)
+		if !ok {
+			unmapped = append(unmapped, fmt.Sprintf("window %d, which isn't in a form tmuxg understands", i))
+			continue
+		}
+		w := config.Window{Name: fmt.Sprintf("window%d", i)}
+		var cmds []string
+		for _, key := range sortedKeys(opts) {
+			switch key {
+			case "name":
+				w.Name, _ = yamlString(opts[key])
+			case "root":
+				root, _ := yamlString(opts[key])
+				w.Cwd = expandHome(root)
+			case "focus":
+				if focus, _ := opts[key].(bool); focus {
+					s.Focus = w.Name
+				}
+			case "clear":
+			case "panes", "splits":
+				panes, _ := opts[key].([]interface{})
+				for j, pane := range panes {
+					if j > 0 {
+						w.Panes = append(w.Panes, config.Pane{Command: shellCommand(teamocilPaneCommands(pane))})
+						continue
+					}
+					cmds = teamocilPaneCommands(pane)
+				}
+			default:
+				unmapped = append(unmapped, fmt.Sprintf("%s of window %q", key, w.Name))
+			}
+		}
+		if s.Focus == fmt.Sprintf("window%d", i) {
+			// Focus was seen before the name.
+			s.Focus = w.Name
+		}
+		w.Command = shellCommand(cmds)
+		s.Windows = append(s.Windows, w)
+	}
+	return &s, unmapped, nil
+}
+
+// teamocilPaneCommands returns the commands run in a pane, which is either a
+// command or a mapping with commands (or cmd, in older layouts).
+func teamocilPaneCommands(pane interface{}) []string {
+	if cmds, ok := yamlStrings(pane); ok {
+		return cmds
+	}
+	opts, _ := yamlMap(pane)
+	for _, key := range []string{"commands", "cmd"} {
+		if cmds, ok := yamlStrings(opts[key]); ok && len(cmds) > 0 {
+			return cmds
+		}
+	}
+	return nil
+}
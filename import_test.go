@@ -0,0 +1,164 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+var importTests = []struct {
+	about    string
+	convert  func(path string, contents []byte) (*config.Session, []string, error)
+	path     string
+	contents string
+	want     *config.Session
+	unmapped []string
+}{{
+	about:   "smug",
+	convert: convertSmug,
+	path:    "blog.yml",
+	contents: `
+session: blog
+root: ~/src/blog
+env:
+  PORT: "8080"
+before_start:
+  - docker compose up -d
+stop:
+  - docker compose stop
+attach: true
+windows:
+  - name: code
+    root: site
+    commands:
+      - git pull
+      - vim
+  - name: server
+    commands: [hugo serve]
+    panes:
+      - type: horizontal
+        root: logs
+        commands: [htop]
+  - name: later
+    manual: true
+`,
+	want: &config.Session{
+		Name:        "blog",
+		Cwd:         "${HOME}/src/blog",
+		Environment: map[string]string{"PORT": "8080"},
+		Hooks: config.Hooks{
+			FirstStart: "docker compose up -d",
+			Stop:       "docker compose stop",
+		},
+		Windows: []config.Window{
+			{Name: "code", Cwd: "${HOME}/src/blog/site", Command: "git pull; vim; exec bash"},
+			{Name: "server", Command: "hugo serve; exec bash", Panes: []config.Pane{
+				{Cwd: "${HOME}/src/blog/logs", Command: "htop; exec bash"},
+			}},
+		},
+	},
+	unmapped: []string{
+		"attach",
+		`type of pane 1 of window "server"`,
+		`window "later", which is only started on request`,
+	},
+}, {
+	about:   "tmuxp",
+	convert: convertTmuxp,
+	path:    "api.yaml",
+	contents: `
+session_name: api
+start_directory: ~/src/api
+shell_command_before:
+  - source .venv/bin/activate
+windows:
+  - window_name: editor
+    focus: true
+    panes:
+      - vim
+  - window_name: tests
+    start_directory: tests
+    shell_command_before:
+      - cmd: export TESTING=1
+    panes:
+      - shell_command: [pytest -f]
+      - htop
+    layout: main-vertical
+`,
+	want: &config.Session{
+		Name:  "api",
+		Cwd:   "${HOME}/src/api",
+		Focus: "editor",
+		Windows: []config.Window{
+			{Name: "editor", Command: "source .venv/bin/activate; vim; exec bash"},
+			{Name: "tests", Cwd: "tests", Command: "source .venv/bin/activate; export TESTING=1; pytest -f; exec bash", Panes: []config.Pane{
+				{Command: "source .venv/bin/activate; export TESTING=1; htop; exec bash"},
+			}},
+		},
+	},
+	unmapped: []string{`layout of window "tests"`},
+}, {
+	about:   "tmuxp JSON",
+	convert: convertTmuxp,
+	path:    "api.json",
+	// Indented with tabs, which YAML doesn't allow.
+	contents: "{\n\t\"session_name\": \"api\",\n\t\"windows\": [\n\t\t{\"window_name\": \"shell\", \"panes\": [\"bash\"]}\n\t]\n}\n",
+	want: &config.Session{
+		Name:    "api",
+		Windows: []config.Window{{Name: "shell", Command: "bash; exec bash"}},
+	},
+}, {
+	about:   "tmuxinator",
+	convert: convertTmuxinator,
+	path:    "shop.yml",
+	contents: `
+name: shop
+root: ~/src/shop
+startup_window: 1
+pre_window: nvm use
+on_project_first_start: bundle install
+windows:
+  - editor: vim
+  - server:
+      root: ~/src/shop/api
+      panes:
+        - rails s
+        - log: tail -f log/development.log
+  - logs:
+      - cd log
+      - less +F production.log
+tmux_options: -f ~/.tmux.shop.conf
+`,
+	want: &config.Session{
+		Name:  "shop",
+		Cwd:   "${HOME}/src/shop",
+		Focus: "server",
+		Hooks: config.Hooks{FirstStart: "bundle install"},
+		Windows: []config.Window{
+			{Name: "editor", Command: "nvm use; vim; exec bash"},
+			{Name: "server", Cwd: "${HOME}/src/shop/api", Command: "nvm use; rails s; exec bash", Panes: []config.Pane{
+				{Title: "log", Command: "nvm use; tail -f log/development.log; exec bash"},
+			}},
+			{Name: "logs", Command: "nvm use; cd log; less +F production.log; exec bash"},
+		},
+	},
+	unmapped: []string{"tmux_options"},
+}}
+
+func TestImport(t *testing.T) {
+	for _, test := range importTests {
+		t.Run(test.about, func(t *testing.T) {
+			s, unmapped, err := test.convert(test.path, []byte(test.contents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s, test.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", s, test.want)
+			}
+			if !reflect.DeepEqual(unmapped, test.unmapped) {
+				t.Errorf("unmapped: got %q, want %q", unmapped, test.unmapped)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func tmuxinatorPaths() ([]string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(homeDir(), ".config")
+	}
+	return globConfigs(
+		filepath.Join(dir, "tmuxinator", "*.yml"),
+		filepath.Join(homeDir(), ".tmuxinator", "*.yml"),
+	)
+}
+
+// convertTmuxinator converts a tmuxinator project.
+func convertTmuxinator(path string, contents []byte) (*config.Session, []string, error) {
+	var unmapped []string
+	if bytes.Contains(contents, []byte("<%")) {
+		unmapped = append(unmapped, "ERB tags, which are imported as they are")
+	}
+	var raw interface{}
+	err := yaml.Unmarshal(contents, &raw)
+	if err != nil {
+		return nil, nil, errgo.Notef(err, "failed to parse")
+	}
+	project, ok := yamlMap(raw)
+	if !ok {
+		return nil, nil, errgo.New("not a tmuxinator project")
+	}
+
+	var s config.Session
+	var preWindow []string
+	for _, key := range sortedKeys(project) {
+		v := project[key]
+		var ok bool
+		switch key {
+		case "name", "project_name":
+			s.Name, ok = yamlString(v)
+		case "root", "project_root":
+			s.Cwd, ok = yamlString(v)
+			s.Cwd = expandHome(s.Cwd)
+		case "startup_window":
+			s.Focus, ok = yamlString(v)
+		case "socket_name":
+			s.Socket.Name, ok = yamlString(v)
+		case "pre_window", "pre_tab", "rbenv", "rvm":
+			var cmds []string
+			cmds, ok = yamlStrings(v)
+			switch key {
+			case "rbenv":
+				cmds = []string{"rbenv shell " + strings.Join(cmds, " ")}
+			case "rvm":
+				cmds = []string{"rvm use " + strings.Join(cmds, " ")}
+			}
+			preWindow = append(preWindow, cmds...)
+		case "on_project_start", "pre":
+			s.Hooks.Start, ok = hookScript(v)
+		case "on_project_first_start":
+			s.Hooks.FirstStart, ok = hookScript(v)
+		case "on_project_restart":
+			s.Hooks.Restart, ok = hookScript(v)
+		case "on_project_exit", "post":
+			s.Hooks.Exit, ok = hookScript(v)
+		case "on_project_stop":
+			s.Hooks.Stop, ok = hookScript(v)
+		case "windows", "tabs":
+			// Converted below, once pre_window is known.
+			ok = true
+		default:
+			unmapped = append(unmapped, key)
+			continue
+		}
+		if !ok {
+			unmapped = append(unmapped, fmt.Sprintf("%s, which isn't in a form tmuxg understands", key))
+		}
+	}
+
+	windows, ok := project["windows"].([]interface{})
+	if !ok {
+		windows, _ = project["tabs"].([]interface{})
+	}
+	for i, item := range windows {
+		w, u := convertTmuxinatorWindow(i, item, preWindow)
+		s.Windows = append(s.Windows, w)
+		unmapped = append(unmapped, u...)
+	}
+	if s.Focus != "" {
+		// tmuxinator focuses windows by name or index.
+		for i, w := range s.Windows {
+			if s.Focus == fmt.Sprint(i) {
+				s.Focus = w.Name
+			}
+		}
+	}
+	return &s, unmapped, nil
+}
+
+// convertTmuxinatorWindow converts a window, which is a single entry
+// mapping its name to a command, a list of commands, or a mapping with root,
+// panes and so on.
+func convertTmuxinatorWindow(i int, item interface{}, preWindow []string) (config.Window, []string) {
+	var w config.Window
+	var unmapped []string
+	// Panes after the first, which is the window's own, are split off it.
+	var splitCmds [][]string
+	var paneTitles []string
+	m, ok := yamlMap(item)
+	if !ok || len(m) != 1 {
+		return config.Window{Name: fmt.Sprintf("window%d", i)}, []string{fmt.Sprintf("window %d, which isn't in a form tmuxg understands", i)}
+	}
+	var v interface{}
+	for name, value := range m {
+		w.Name, v = name, value
+	}
+
+	cmds, ok := yamlStrings(v)
+	if !ok {
+		opts, _ := yamlMap(v)
+		for _, key := range sortedKeys(opts) {
+			switch key {
+			case "root":
+				root, _ := yamlString(opts[key])
+				w.Cwd = expandHome(root)
+			case "pre":
+				pre, _ := yamlStrings(opts[key])
+				preWindow = append(append([]string(nil), preWindow...), pre...)
+			case "panes":
+				panes, _ := opts[key].([]interface{})
+				for j, pane := range panes {
+					var title string
+					paneCmds, ok := yamlStrings(pane)
+					if !ok {
+						// A named pane maps its name to commands.
+						if named, ok := yamlMap(pane); ok && len(named) == 1 {
+							for name, v := range named {
+								title = name
+								paneCmds, _ = yamlStrings(v)
+							}
+						}
+					}
+					if j == 0 {
+						cmds = paneCmds
+					} else {
+						paneTitles = append(paneTitles, title)
+						splitCmds = append(splitCmds, paneCmds)
+					}
+				}
+			default:
+				unmapped = append(unmapped, fmt.Sprintf("%s of window %q", key, w.Name))
+			}
+		}
+	}
+	w.Command = shellCommand(append(append([]string(nil), preWindow...), cmds...))
+	for j, paneCmds := range splitCmds {
+		w.Panes = append(w.Panes, config.Pane{
+			Title:   paneTitles[j],
+			Command: shellCommand(append(append([]string(nil), preWindow...), paneCmds...)),
+		})
+	}
+	return w, unmapped
+}
+
+// hookScript returns a hook's commands as a script.
+func hookScript(v interface{}) (string, bool) {
+	cmds, ok := yamlStrings(v)
+	return strings.Join(cmds, "\n"), ok
+}
+
+// shellCommand joins commands into a window command. Other tools type
+// commands into a shell, so the window is left at a shell afterwards.
+func shellCommand(cmds []string) string {
+	if len(cmds) == 0 {
+		return ""
+	}
+	return strings.Join(cmds, "; ") + "; exec bash"
+}
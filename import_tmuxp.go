@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+func tmuxpPaths() ([]string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(homeDir(), ".config")
+	}
+	var patterns []string
+	for _, d := range []string{filepath.Join(dir, "tmuxp"), filepath.Join(homeDir(), ".tmuxp")} {
+		for _, ext := range []string{"yaml", "yml", "json"} {
+			patterns = append(patterns, filepath.Join(d, "*."+ext))
+		}
+	}
+	return globConfigs(patterns...)
+}
+
+// convertTmuxp converts a tmuxp workspace, in YAML or JSON.
+func convertTmuxp(path string, contents []byte) (*config.Session, []string, error) {
+	var raw interface{}
+	var err error
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(contents, &raw)
+	} else {
+		err = yaml.Unmarshal(contents, &raw)
+	}
+	if err != nil {
+		return nil, nil, errgo.Notef(err, "failed to parse")
+	}
+	workspace, ok := yamlMap(raw)
+	if !ok {
+		return nil, nil, errgo.New("not a tmuxp workspace")
+	}
+
+	var s config.Session
+	var unmapped []string
+	var before []string
+	for _, key := range sortedKeys(workspace) {
+		v := workspace[key]
+		switch key {
+		case "session_name":
+			s.Name, _ = yamlString(v)
+		case "start_directory":
+			dir, _ := yamlString(v)
+			s.Cwd = expandHome(dir)
+		case "shell_command_before":
+			before = tmuxpCommands(v)
+		case "environment":
+			env, _ := yamlMap(v)
+			s.Environment = map[string]string{}
+			for k, v := range env {
+				s.Environment[k], _ = yamlString(v)
+			}
+		case "windows":
+		default:
+			unmapped = append(unmapped, key)
+		}
+	}
+
+	windows, _ := workspace["windows"].([]interface{})
+	for i, item := range windows {
+		opts, ok := yamlMap(item)
+		if !ok {
+			unmapped = append(unmapped, fmt.Sprintf("window %d, which isn't in a form tmuxg understands", i))
+			continue
+		}
+		w := config.Window{Name: fmt.Sprintf("window%d", i)}
+		if name, ok := yamlString(opts["window_name"]); ok && name != "" {
+			w.Name = name
+		}
+		cmds := before
+		var paneCmds [][]string
+		for _, key := range sortedKeys(opts) {
+			v := opts[key]
+			switch key {
+			case "window_name":
+			case "start_directory":
+				dir, _ := yamlString(v)
+				w.Cwd = expandHome(dir)
+			case "shell_command_before":
+				cmds = append(append([]string(nil), cmds...), tmuxpCommands(v)...)
+			case "focus":
+				if focus, _ := v.(bool); focus || v == "true" {
+					s.Focus = w.Name
+				}
+			case "panes":
+				panes, _ := v.([]interface{})
+				for _, pane := range panes {
+					paneCmds = append(paneCmds, tmuxpPaneCommands(pane))
+				}
+			default:
+				unmapped = append(unmapped, fmt.Sprintf("%s of window %q", key, w.Name))
+			}
+		}
+		// The first pane is the window's own; the rest are split off it.
+		var first []string
+		if len(paneCmds) > 0 {
+			first = paneCmds[0]
+		}
+		w.Command = shellCommand(append(append([]string(nil), cmds...), first...))
+		for j := 1; j < len(paneCmds); j++ {
+			w.Panes = append(w.Panes, config.Pane{
+				Command: shellCommand(append(append([]string(nil), cmds...), paneCmds[j]...)),
+			})
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	return &s, unmapped, nil
+}
+
+// tmuxpCommands returns a list of commands, each either a string or, in
+// newer workspaces, a mapping with the command as cmd.
+func tmuxpCommands(v interface{}) []string {
+	if cmds, ok := yamlStrings(v); ok {
+		return cmds
+	}
+	list, _ := v.([]interface{})
+	var cmds []string
+	for _, item := range list {
+		if cmd, ok := yamlString(item); ok {
+			cmds = append(cmds, cmd)
+		} else if m, ok := yamlMap(item); ok {
+			cmd, _ := yamlString(m["cmd"])
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// tmuxpPaneCommands returns the commands run in a pane, which is either
+// commands or a mapping with commands as shell_command.
+func tmuxpPaneCommands(pane interface{}) []string {
+	if m, ok := yamlMap(pane); ok {
+		return tmuxpCommands(m["shell_command"])
+	}
+	return tmuxpCommands(pane)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// localSessionFile is the name of a session file kept in a project's
+// repository.
+const localSessionFile = ".tmuxg.yaml"
+
+// findLocalSession returns the path of the session file of the project
+// containing the working directory, or "" if there isn't one.
+func findLocalSession() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for d := wd; ; d = filepath.Dir(d) {
+		path := filepath.Join(d, localSessionFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// initCommand writes a session file into the root of the project it is run
+// in, and registers it in the config directory, so that the session can be
+// started by name from anywhere, or without a name from within the project.
+func initCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	tmpl := fs.String("template", *templateFlag, "template for the new session file")
+	fs.Var(setFlag, "set", "set a template variable, as name=value (may be repeated)")
+	args, err := parseCommandFlags("init", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if len(args) > 1 {
+		return errgo.Mask(commandArgs("init", args, 1), errgo.Any)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	root, _ := detectProject(wd)
+	if root == "" {
+		root = wd
+	}
+	name := filepath.Base(root)
+	if len(args) == 1 {
+		name = args[0]
+	}
+
+	path := filepath.Join(root, localSessionFile)
+	if _, err := os.Stat(path); err == nil {
+		return errgo.Newf("%q already exists", path)
+	}
+	*templateFlag = *tmpl
+	err = createSessionFile(name, path)
+	if err != nil {
+		os.Remove(path)
+		return errgo.Mask(err)
+	}
+
+	err = os.MkdirAll(config.Dir(), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create config directory")
+	}
+	link := filepath.Join(config.Dir(), name+".yaml")
+	err = os.Symlink(path, link)
+	if os.IsExist(err) {
+		log.Printf("not registering %q, as session %q already exists in %q", path, name, link)
+		return nil
+	} else if err != nil {
+		return errgo.Notef(err, "failed to register session %q", name)
+	}
+	return nil
+}
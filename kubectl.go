@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// listPods returns the names of the running pods that k selects.
+func listPods(s *config.Session, k *config.Kubectl) ([]string, error) {
+	args := k.Args(s.ExpandEnv, "get", "pods", "-o", "name",
+		"--field-selector", "status.phase=Running", "-l", s.ExpandEnv(k.Selector))
+	c := exec.Command(args[0], args[1:]...)
+	c.Env = s.Env()
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to list pods matching %q", k.Selector)
+	}
+	var pods []string
+	for _, pod := range strings.Fields(string(out)) {
+		pods = append(pods, strings.TrimPrefix(pod, "pod/"))
+	}
+	return pods, nil
+}
+
+// kubectlWindows returns the windows that a kubectl window opens onto its
+// pods.
+func kubectlWindows(s *config.Session, w config.Window) ([]config.Window, error) {
+	k := w.Kubectl
+	w.Kubectl = nil
+	var container []string
+	if k.Container != "" {
+		container = []string{"--container", s.ExpandEnv(k.Container)}
+	}
+
+	switch k.Mode {
+	case config.KubectlStern:
+		args := []string{"stern", "-l", s.ExpandEnv(k.Selector)}
+		if k.Context != "" {
+			args = append(args, "--context", s.ExpandEnv(k.Context))
+		}
+		if k.Namespace != "" {
+			args = append(args, "--namespace", s.ExpandEnv(k.Namespace))
+		}
+		if k.Container != "" {
+			args = append(args, "--container", s.ExpandEnv(k.Container))
+		}
+		w.Command = config.ShellQuote(args...)
+		return []config.Window{w}, nil
+	case "", config.KubectlLogs, config.KubectlExec:
+	default:
+		return nil, errgo.Newf("unknown kubectl mode %q in window %q", k.Mode, w.Name)
+	}
+
+	pods, err := listPods(s, k)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	if len(pods) == 0 {
+		log.Printf("no pods match window %q", w.Name)
+	}
+	var windows []config.Window
+	for _, pod := range pods {
+		var args []string
+		if k.Mode == config.KubectlExec {
+			command := k.Command
+			if command == "" {
+				command = "sh"
+			}
+			args = append(k.Args(s.ExpandEnv, "exec", "-it", pod), container...)
+			args = append(args, "--", "sh", "-c", command)
+		} else {
+			args = append(k.Args(s.ExpandEnv, "logs", "-f", pod), container...)
+		}
+		podWindow := w
+		podWindow.Name = w.Name + "/" + pod
+		podWindow.Command = config.ShellQuote(args...)
+		windows = append(windows, podWindow)
+	}
+	return windows, nil
+}
+
+// expandKubectl replaces the session's kubectl windows with the windows
+// they open onto their pods.
+func expandKubectl(s *config.Session) error {
+	var windows []config.Window
+	for _, w := range s.Windows {
+		if w.Kubectl == nil {
+			windows = append(windows, w)
+			continue
+		}
+		podWindows, err := kubectlWindows(s, w)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		windows = append(windows, podWindows...)
+	}
+	s.Windows = windows
+	return nil
+}
+
+// refreshPodsCommand brings a running session's kubectl windows up to date
+// with the pods they select, closing the windows of pods that have gone and
+// opening windows onto new ones.
+func refreshPodsCommand(conf *config.Config, args []string) error {
+	err := commandArgs("refresh-pods", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	session, ok := b.(*tmux.Session)
+	if !ok {
+		return errgo.Newf("refreshing pods needs the tmux backend")
+	}
+	if !backend.Running(b) {
+		return errgo.Newf("session %q is not running", s.Name)
+	}
+
+	out, err := session.Output("list-windows", "-t", s.Name, "-F", "#{window_index} #{window_name}")
+	if err != nil {
+		return errgo.Notef(err, "failed to list windows of session %q", s.Name)
+	}
+	running := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+			running[fields[1]] = fields[0]
+		}
+	}
+
+	for _, w := range s.Windows {
+		if w.Kubectl == nil || w.Kubectl.Mode == config.KubectlStern {
+			continue
+		}
+		podWindows, err := kubectlWindows(s, w)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		wanted := make(map[string]bool)
+		for _, pw := range podWindows {
+			wanted[pw.Name] = true
+			if _, ok := running[pw.Name]; ok {
+				continue
+			}
+			fmt.Printf("opening %s\n", pw.Name)
+			out, err := session.Output("new-window", "-d", "-P", "-F", "#{window_index}",
+				"-t", s.Name+":", "-n", pw.Name,
+				"-c", backend.WindowCwd(s, &pw), backend.WindowCommand(s, &pw))
+			if err != nil {
+				return errgo.Notef(err, "failed to open window %q", pw.Name)
+			}
+			if len(pw.Keystrokes) > 0 {
+				target := s.Name + ":" + strings.TrimSpace(string(out))
+				err = backend.SendKeystrokes(session, target, pw.Keystrokes)
+				if err == nil {
+					err = session.Flush()
+				}
+				if err != nil {
+					return errgo.Notef(err, "failed to send keystrokes to window %q", pw.Name)
+				}
+			}
+		}
+		for name, index := range running {
+			if strings.HasPrefix(name, w.Name+"/") && !wanted[name] {
+				fmt.Printf("closing %s\n", name)
+				err := session.Run("kill-window", "-t", s.Name+":"+index)
+				if err != nil {
+					return errgo.Notef(err, "failed to close window %q", name)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/errgo.v1"
+)
+
+// launchdAgent is the launchd user agent that starts a session at login.
+// It's given the agent's label, the tmuxg executable, the session file and
+// $PATH.
+const launchdAgent = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%[1]s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%[2]s</string>
+		<string>-no-attach</string>
+		<string>start</string>
+		<string>%[3]s</string>
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>%[4]s</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`
+
+// launchdLabel returns the label of the launchd agent for the named
+// session.
+func launchdLabel(name string) string {
+	return "com.github.cmars.tmuxg." + name
+}
+
+// launchdAgentPath returns the path of the launchd agent for the named
+// session.
+func launchdAgentPath(name string) string {
+	return filepath.Join(os.Getenv("HOME"), "Library", "LaunchAgents", launchdLabel(name)+".plist")
+}
+
+func installLaunchdAgent(name, tmuxg, path string) error {
+	agentPath := launchdAgentPath(name)
+	err := os.MkdirAll(filepath.Dir(agentPath), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create launch agent directory")
+	}
+	agent := fmt.Sprintf(launchdAgent, html.EscapeString(launchdLabel(name)),
+		html.EscapeString(tmuxg), html.EscapeString(path), html.EscapeString(os.Getenv("PATH")))
+	err = ioutil.WriteFile(agentPath, []byte(agent), 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write launch agent %q", agentPath)
+	}
+	err = launchctl("load", "-w", agentPath)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	fmt.Printf("installed %s\n", agentPath)
+	return nil
+}
+
+func uninstallLaunchdAgent(name string) error {
+	agentPath := launchdAgentPath(name)
+	if _, err := os.Stat(agentPath); err != nil {
+		return errgo.Newf("session %q has no service installed", name)
+	}
+	err := launchctl("unload", "-w", agentPath)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = os.Remove(agentPath)
+	if err != nil {
+		return errgo.Notef(err, "failed to remove launch agent %q", agentPath)
+	}
+	fmt.Printf("removed %s\n", agentPath)
+	return nil
+}
+
+func launchctl(args ...string) error {
+	c := exec.Command("launchctl", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	err := c.Run()
+	if err != nil {
+		return errgo.Notef(err, "launchctl %s failed", args[0])
+	}
+	return nil
+}
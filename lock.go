@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"gopkg.in/errgo.v1"
+)
+
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "tmuxg")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tmuxg-%d", os.Getuid()))
+}
+
+// lockSession takes an exclusive lock on starting the named session, so that
+// concurrent tmuxg invocations don't race each other to create it. If another
+// tmuxg holds the lock, lockSession waits for it to finish, and reports that
+// it had to wait.
+func lockSession(name string) (unlock func(), waited bool, err error) {
+	dir := runtimeDir()
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, false, errgo.Notef(err, "failed to create runtime directory %q", dir)
+	}
+	lockPath := filepath.Join(dir, name+".lock")
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, false, errgo.Notef(err, "failed to open lock file %q", lockPath)
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		log.Printf("waiting for another tmuxg to finish starting session %q", name)
+		waited = true
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	}
+	if err != nil {
+		f.Close()
+		return nil, false, errgo.Notef(err, "failed to lock %q", lockPath)
+	}
+
+	var unlocked bool
+	return func() {
+		if !unlocked {
+			unlocked = true
+			f.Close()
+		}
+	}, waited, nil
+}
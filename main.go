@@ -3,43 +3,55 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
-	"text/template"
 
 	"gopkg.in/errgo.v1"
-	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
 )
 
-var userFlag = flag.String("user", "", "default github user")
-var projectFlag = flag.String("project", "", "default github project")
+var userFlag = flag.String("user", "", "default forge user")
+var projectFlag = flag.String("project", "", "default forge project")
+var forgeFlag = flag.String("forge", "github.com", "default forge host, such as gitlab.com")
+var sshFlag = flag.Bool("ssh", false, "clone new projects over SSH")
+var remoteFlag = flag.String("remote", "", "git URL to clone new projects from, instead of the forge")
+var templateFlag = flag.String("template", "", "template for new session files: go, node, python, rust or generic (default detected)")
 var editFlag = flag.Bool("edit", false, "edit config")
 var setupFlag = flag.Bool("setup", false, "run project setup")
-
-type session struct {
-	Name        string            `yaml:"name"`
-	SetupScript string            `yaml:"setup-script"`
-	Environment map[string]string `yaml:"environment"`
-	Cwd         string            `yaml:"cwd"`
-	Windows     []window          `yaml:"windows"`
-	Focus       string            `yaml:"focus"`
-}
-
-type window struct {
-	Name       string   `yaml:"name"`
-	Command    string   `yaml:"command"`
-	Cwd        string   `yaml:"cwd"`
-	Keystrokes []string `yaml:"keystrokes"`
-}
+var logFileFlag = flag.String("log-file", "", "also write diagnostic output to this file")
+var debugEnvFlag = flag.Bool("debug-env", false, "log environment variables set by the session")
+var keepPartialFlag = flag.Bool("keep-partial", false, "keep a partially created session if startup fails")
+var recreateFlag = flag.Bool("recreate", false, "kill and rebuild the session if it is already running")
+var allowNestedFlag = flag.Bool("allow-nested", false, "allow attaching from inside another tmux server")
+var noAttachFlag = flag.Bool("no-attach", false, "build the session but don't attach to it")
+var readOnlyFlag = flag.Bool("read-only", false, "attach to the session read-only")
+var detachOthersFlag = flag.Bool("detach-others", false, "detach other clients attached to the session")
+var notifySetupFlag = flag.Bool("notify-setup", false, "send a desktop notification when the setup script finishes")
+var setupOnlyFlag = flag.Bool("setup-only", false, "run the session's setup scripts, and nothing else")
+var attachSummaryFlag = flag.Bool("attach-summary", false, "on detaching, say how to attach again")
+var killExistingFlag = flag.Bool("kill-existing", false, "kill the session's tmux server, if running, and rebuild the session")
+var recentFlag = flag.Bool("recent", false, "list the sessions tmuxg has started, most recent first")
+
+// logFile receives a copy of tmuxg's diagnostic output, when configured.
+var logFile *os.File
 
 func die(err error) {
 	if err != nil {
-		fmt.Fprintln(os.Stderr, errgo.Details(err))
-		os.Exit(1)
+		reportError(os.Stderr, err)
+		if logFile != nil {
+			fmt.Fprintln(logFile, errgo.Details(err))
+		}
+		os.Exit(failureOf(err).code)
 	}
 }
 
@@ -49,16 +61,62 @@ func main() {
 
 func run() error {
 	flag.Parse()
+	if *errorFormatFlag != "text" && *errorFormatFlag != "json" {
+		return errgo.WithCausef(nil, errUsage, "unknown error format %q", *errorFormatFlag)
+	}
+
+	conf, err := config.LoadConfig()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = openLogFile(conf)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if conf.NotifySetup {
+		*notifySetupFlag = true
+	}
 
+	if *recentFlag {
+		return errgo.Mask(printRecent())
+	}
+
+	var arg string
 	if flag.NArg() < 1 {
-		fmt.Fprintln(os.Stderr, "usage: %s <session.yaml file>", os.Args[0])
-		return errgo.New("missing session file argument")
+		// Without a session, start the project tmuxg is run in.
+		arg = findLocalSession()
+		if arg == "" {
+			usage()
+			return errgo.WithCausef(nil, errUsage, "missing session file argument")
+		}
+	} else {
+		arg = flag.Arg(0)
+	}
+	if cmd, ok := commands[arg]; ok {
+		return errgo.Mask(cmd.run(conf, flag.Args()[1:]), errgo.Any)
+	}
+	if flag.NArg() > 0 {
+		if path, ok := findPlugin(arg); ok {
+			return errgo.Mask(runPlugin(conf, path, flag.Args()[1:]), errgo.Any)
+		}
 	}
 
-	name, err := locateSession(flag.Arg(0))
-	if os.IsNotExist(err) || *editFlag {
+	return errgo.Mask(startSession(conf, arg), errgo.Any)
+}
+
+// startSession starts the session named arg, writing a session file for it
+// first if there isn't one, and attaches to it. Without a terminal to edit
+// a new session file in, or with -setup-only, a missing session fails with
+// errConfigNotFound instead.
+func startSession(conf *config.Config, arg string) error {
+	_, err := config.Locate(arg)
+	if os.IsNotExist(err) && (*setupOnlyFlag || !isTerminal(os.Stdin)) {
+		return errgo.WithCausef(err, errConfigNotFound, "no session %q", arg)
+	} else if os.IsNotExist(err) || *editFlag {
 		*setupFlag = true
-		err = newSessionFile(flag.Arg(0))
+		err = newSessionFile(arg)
+	} else if err != nil {
+		return errgo.WithCausef(err, errConfigNotFound, "")
 	}
 	if err != nil {
 		return errgo.Mask(err)
@@ -67,125 +125,340 @@ func run() error {
 		os.Exit(0)
 	}
 
-	session, err := newSession(name)
+	session, err := loadSession(conf, arg)
 	if err != nil {
-		return errgo.Mask(err)
+		return errgo.Mask(err, errgo.Any)
 	}
-
-	for k, v := range session.Environment {
-		os.Setenv(k, os.ExpandEnv(v))
+	if *setupOnlyFlag {
+		debugEnvironment(session)
+		return errgo.Mask(setupOnly(session), errgo.Any)
 	}
-	if _, err := os.Stat(session.Cwd); os.IsNotExist(err) {
-		*setupFlag = true
+	if path, err := config.Locate(arg); err == nil {
+		recordLaunch(session, path)
+	}
+	b, err := newBackend(conf, session)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	if !backend.Running(b) {
+		err = startRequiredSessions(conf, session)
+		if err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
 	}
 
-	log.Println(os.Environ())
+	debugEnvironment(session)
+	return errgo.Mask(startBackend(b, session, session.Name, localSetup(session)), errgo.Any)
+}
+
+// requiring holds the sessions whose required sessions are being started,
+// to catch sessions that require each other.
+var requiring = make(map[string]bool)
 
-	if *setupFlag {
-		err = session.setupScript()
+// startRequiredSessions starts the sessions s requires that aren't running,
+// without attaching to them.
+func startRequiredSessions(conf *config.Config, s *config.Session) error {
+	if len(s.RequiresSessions) == 0 {
+		return nil
+	}
+	if requiring[s.Name] {
+		return errgo.WithCausef(nil, errParse, "session %q requires itself, through requires-sessions", s.Name)
+	}
+	requiring[s.Name] = true
+	defer delete(requiring, s.Name)
+
+	noAttach := *noAttachFlag
+	*noAttachFlag = true
+	defer func() { *noAttachFlag = noAttach }()
+	for _, name := range s.RequiresSessions {
+		required, err := loadSession(conf, name)
+		if err != nil {
+			return errgo.Notef(err, "failed to load session %q, required by %q", name, s.Name)
+		}
+		b, err := newBackend(conf, required)
+		if err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
+		if backend.Running(b) {
+			continue
+		}
+		err = startRequiredSessions(conf, required)
+		if err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
+		log.Printf("starting session %q, required by %q", name, s.Name)
+		err = startBackend(b, required, required.Name, localSetup(required))
 		if err != nil {
-			return errgo.Notef(err, "failed to execute setup script")
+			return errgo.Notef(err, "failed to start session %q, required by %q", name, s.Name)
 		}
 	}
+	return nil
+}
 
-	err = session.create()
+// setupOnly runs the session's setup scripts, for -setup-only, without
+// starting the session.
+func setupOnly(s *config.Session) error {
+	unlock, _, err := lockSession(s.Name)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer unlock()
+	err = runSetupScript(s)
+	if err != nil {
+		return errgo.WithCausef(err, errSetupFailed, "failed to execute setup script")
+	}
+	if hasSetupScripts(s) {
+		notifyEvent(s, config.EventSetupFinished)
+	}
+	log.Printf("session %q is set up", s.Name)
+	return nil
+}
+
+// localSetup returns runSetupScript if the session needs setting up, when
+// asked to, when tmuxg has no record of setting it up, or when its setup
+// scripts have changed since it was set up, or nil if not.
+func localSetup(s *config.Session) func(*config.Session) error {
+	if *setupFlag {
+		return runSetupScript
+	}
+	if !hasSetupScripts(s) {
+		return nil
+	}
+	hash, _, err := readSetupState(s)
+	switch {
+	case os.IsNotExist(err):
+		return runSetupScript
+	case err != nil:
+		log.Printf("%v", err)
+		return runSetupScript
+	case hash != setupHash(s):
+		log.Printf("setup scripts of %q changed since it was set up, running them again", s.Name)
+		return runSetupScript
+	}
+	return nil
+}
+
+// startBackend builds the session with b, unless it's already running, and
+// attaches to it. Starting the session is locked under lockName, and setup,
+// if set, runs the setup script.
+func startBackend(b backend.Backend, session *config.Session, lockName string, setup func(*config.Session) error) error {
+	unlock, waited, err := lockSession(lockName)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer unlock()
+
+	err = runHook(session, "start", session.Hooks.Start)
 	if err != nil {
 		return errgo.Mask(err)
 	}
 
-	var focus int
-	for i, window := range session.Windows {
-		if i > 0 {
-			err = session.createWindow(i, &window)
+	// A session that is already running is attached to as it is, unless
+	// asked to rebuild it. If another tmuxg was just starting it, that's
+	// the session to attach to, even with -recreate or -kill-existing.
+	running := backend.Running(b)
+	if running && !waited {
+		ts, isTmux := b.(*tmux.Session)
+		switch {
+		case *killExistingFlag && isTmux && ts.OwnsServer():
+			// A wedged session may have left its server in a bad way
+			// too, so start over from scratch.
+			err = ts.KillServer()
+		case *killExistingFlag:
+			err = b.Kill()
+		case *recreateFlag:
+			err = b.Kill()
+		}
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if *killExistingFlag || *recreateFlag {
+			notifyEvent(session, config.EventSessionKilled)
+		}
+		running = backend.Running(b)
+	}
+	if !running {
+		// Setup in a window happens as the session is built.
+		_, isTmux := b.(*tmux.Session)
+		inWindow := setup != nil && session.Setup.InWindow && isTmux && hasSetupScripts(session)
+		if setup != nil && !inWindow {
+			err = setup(session)
+			if err == nil && hasSetupScripts(session) {
+				notifyEvent(session, config.EventSetupFinished)
+			}
+			if *notifySetupFlag && hasSetupScripts(session) {
+				if err != nil {
+					desktopNotify("tmuxg", fmt.Sprintf("Setup of %s failed", session.Name))
+				} else {
+					desktopNotify("tmuxg", fmt.Sprintf("Setup of %s finished", session.Name))
+				}
+			}
 			if err != nil {
-				return errgo.Mask(err)
+				return errgo.WithCausef(err, errSetupFailed, "failed to execute setup script")
 			}
 		}
 
-		err = session.sendKeys(&window)
+		err = runHook(session, "first-start", session.Hooks.FirstStart)
 		if err != nil {
 			return errgo.Mask(err)
 		}
-
-		if session.Focus == window.Name {
-			focus = i
+		err = composeUp(session)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		err = vagrantUp(session)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		err = expandKubectl(session)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		err = expandWorktrees(session)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if inWindow {
+			err = addSetupWindow(session)
+			if err != nil {
+				return errgo.Mask(err)
+			}
+		}
+		err = backend.Build(b, session, *keepPartialFlag)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		notifyEvent(session, config.EventSessionCreated)
+	} else {
+		// Windows added to the session file since the session
+		// started are added to it.
+		added, err := backend.AddWindows(b, session)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if len(added) > 0 {
+			log.Printf("added windows %q to session %q", added, session.Name)
+		}
+		err = runHook(session, "restart", session.Hooks.Restart)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		if ts, ok := b.(*tmux.Session); ok && session.Hooks.Broadcast != "" {
+			err = broadcast(ts, session.Hooks.Broadcast)
+			if err != nil {
+				return errgo.Mask(err)
+			}
 		}
 	}
-	err = session.focus(focus)
+	unlock()
+
+	if *noAttachFlag {
+		log.Printf("session %q is ready", session.Name)
+		return nil
+	}
+	err = b.Attach(backend.AttachOptions{
+		AllowNested:  *allowNestedFlag,
+		ReadOnly:     *readOnlyFlag,
+		DetachOthers: *detachOthersFlag,
+	})
+	if errgo.Cause(err) == tmux.ErrNested {
+		return errgo.Mask(err, errgo.Is(tmux.ErrNested))
+	}
+
+	// Detaching, or the session ending as its windows exit, is a clean
+	// end. A failed attach is told from the session's server going away
+	// under it by whether the session outlived it.
+	running = backend.Running(b)
 	if err != nil {
-		return errgo.Mask(err)
+		if running {
+			return errgo.WithCausef(err, errAttachFailed, "")
+		}
+		return errgo.WithCausef(err, errSessionEnded, "session %q ended", session.Name)
+	}
+	hookErr := runHook(session, "exit", session.Hooks.Exit)
+	if running && *attachSummaryFlag {
+		fmt.Fprintf(os.Stderr, "session %q is still running, reattach with: %s\n",
+			session.Name, reattachCommand(session, lockName))
 	}
+	return errgo.Mask(hookErr)
+}
 
-	err = session.tmux("attach", "-t", session.Name)
-	return errgo.Mask(err)
+// reattachCommand returns the tmuxg command that attaches to the session
+// again. Sessions on another host are locked under name@host.
+func reattachCommand(s *config.Session, lockName string) string {
+	if i := strings.Index(lockName, "@"); i >= 0 {
+		return fmt.Sprintf("tmuxg start %s -host %s", s.Name, lockName[i+1:])
+	}
+	return "tmuxg " + s.Name
 }
 
-func locateSession(name string) (string, error) {
-	if info, err := os.Stat(name); err == nil && !info.IsDir() {
-		return name, nil
-	} else if err != nil && !os.IsNotExist(err) {
-		return "", errgo.Notef(err, "failed to open session file %q", name)
+// debugEnvironment logs the environment variables the session sets, with
+// -debug-env. They're given to the commands tmuxg runs for the session,
+// rather than set in tmuxg's own environment.
+func debugEnvironment(s *config.Session) {
+	if !*debugEnvFlag {
+		return
+	}
+	var changes []envChange
+	for k := range s.Environment {
+		v := s.Getenv(k)
+		old, ok := os.LookupEnv(k)
+		if !ok || old != v {
+			changes = append(changes, envChange{name: k, value: v, modified: ok})
+		}
 	}
+	logEnv(changes)
+}
 
-	configDir := os.Getenv("XDG_CONFIG_HOME")
-	if configDir == "" {
-		configDir = filepath.Join(os.Getenv("HOME"), ".config")
+func openLogFile(conf *config.Config) error {
+	path := *logFileFlag
+	if path == "" {
+		path = conf.LogFile
 	}
+	if path == "" {
+		return nil
+	}
+	path = os.ExpandEnv(path)
 
-	tmuxgConfigDir := filepath.Join(configDir, "tmuxg")
-	err := os.MkdirAll(tmuxgConfigDir, 0644)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
 	if err != nil {
-		return "", errgo.Notef(err, "failed to create config directory %q", tmuxgConfigDir)
+		return errgo.Notef(err, "failed to create log directory for %q", path)
 	}
-
-	confPath := filepath.Join(tmuxgConfigDir, name+".yaml")
-	if _, err := os.Stat(confPath); err != nil {
-		if os.IsNotExist(err) {
-			return "", err
-		}
-		return "", errgo.Notef(err, "failed to resolve session %q file %q", name, confPath)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errgo.Notef(err, "failed to open log file %q", path)
 	}
-	return confPath, nil
+	logFile = f
+	log.SetOutput(io.MultiWriter(os.Stderr, f))
+	return nil
+}
+
+// envChange records an environment variable set by the session.
+type envChange struct {
+	name, value string
+	modified    bool
 }
 
-const newTemplateContents = `
-# Name of the session. Probably don't mess with this.
-name: {{.Name}}
-
-# Environment variables set for the tmux session.
-environment:
-  GOPATH: ${HOME}/go/{{.Name}}
-
-# Current working directory for the tmux session. May use environment variables
-# declared above.
-cwd: ${GOPATH}/src/github.com/{{.User}}/{{.Project}}
-
-# Script to run the first time this session starts, or when specifically
-# invoked with -setup. Try to make this script idempotent.
-setup-script: |
-    #!/bin/bash
-    mkdir -p ${GOPATH}
-    go get -d github.com/{{.User}}/{{.Project}}/...
-
-# Windows to create in the tmux session and what to run in each.
-windows:
-  - name: editor
-    command: vim
-    keystrokes:
-      - \n
-  - name: shell
-focus: editor
-`
-
-var newTemplate = template.Must(template.New("new-conf").Parse(newTemplateContents))
+var sensitiveEnvPattern = regexp.MustCompile(`(?i)(TOKEN|KEY|SECRET|PASSWORD|PASSWD|CREDENTIAL)`)
 
-func newSessionFile(name string) error {
-	configDir := os.Getenv("XDG_CONFIG_HOME")
-	if configDir == "" {
-		configDir = filepath.Join(os.Getenv("HOME"), ".config")
+func logEnv(changes []envChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].name < changes[j].name })
+	for _, c := range changes {
+		value := c.value
+		if sensitiveEnvPattern.MatchString(c.name) {
+			value = "********"
+		}
+		action := "set"
+		if c.modified {
+			action = "modified"
+		}
+		log.Printf("env: %s %s=%s", action, c.name, value)
 	}
+}
 
-	tmuxgConfigDir := filepath.Join(configDir, "tmuxg")
+func newSessionFile(name string) error {
+	tmuxgConfigDir := config.Dir()
 	err := os.MkdirAll(tmuxgConfigDir, 0644)
 	if err != nil {
 		return errgo.Notef(err, "failed to create config directory %q", tmuxgConfigDir)
@@ -207,22 +480,24 @@ func createSessionFile(name, confPath string) error {
 			}
 			defer f.Close()
 
-			user := *userFlag
-			if user == "" {
-				user = os.Getenv("USER")
+			// Sessions created inside a project are set up for it.
+			var root, detected string
+			if wd, err := os.Getwd(); err == nil {
+				root, detected = detectProject(wd)
 			}
-			project := *projectFlag
-			if project == "" {
-				project = name
+			templateName := *templateFlag
+			if templateName == "" {
+				templateName = detected
 			}
 
-			err = newTemplate.Execute(f, struct {
-				Name, User, Project string
-			}{
-				Name:    name,
-				User:    user,
-				Project: project,
-			})
+			tmpl, err := loadTemplate(templateName)
+			if err != nil {
+				return errgo.Mask(err)
+			}
+			err = tmpl.Execute(f, newTemplateData(name, root))
+			if err == nil && hasDevcontainer(root) {
+				_, err = fmt.Fprintf(f, devcontainerHint, root)
+			}
 			if err != nil {
 				return errgo.Notef(err, "failed to write config file %q", confPath)
 			}
@@ -238,122 +513,93 @@ func createSessionFile(name, confPath string) error {
 	if err != nil {
 		return errgo.Notef(err, "editor exited with error")
 	}
-	_, err = newSession(confPath)
+	_, err = config.Load(confPath)
 	return err
 }
 
-func newSession(confPath string) (*session, error) {
-	var s session
-
-	contents, err := ioutil.ReadFile(confPath)
+// loadSession reads an existing session, given its name or the path to its
+// file.
+func loadSession(conf *config.Config, arg string) (*config.Session, error) {
+	path, err := config.Locate(arg)
 	if err != nil {
-		return nil, errgo.Notef(err, "failed to read session file")
-	}
-	err = yaml.Unmarshal(contents, &s)
-	if err != nil {
-		return nil, errgo.Notef(err, "failed to parse session file")
-	}
-
-	for i := range s.Windows {
-		if s.Windows[i].Command == "" {
-			s.Windows[i].Command = "bash"
-		}
-	}
-	return &s, nil
-}
-
-func (s *session) tmux(args ...string) error {
-	c := exec.Command("tmux", append([]string{"-L", s.Name}, args...)...)
-	c.Stdin = os.Stdin
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	c.Dir = os.ExpandEnv(s.Cwd)
-	log.Printf("%v", c)
-	return errgo.Mask(c.Run())
-}
-
-func (s *session) setupScript() error {
-	if s.SetupScript == "" {
-		return nil
+		return nil, errgo.WithCausef(err, errConfigNotFound, "")
 	}
-
-	f, err := ioutil.TempFile("", "tmuxg-setup")
+	contents, err := verifySession(conf, path)
 	if err != nil {
-		return errgo.Notef(err, "failed to create temporary file for script")
+		return nil, errgo.Mask(err, errgo.Any)
 	}
-	defer os.Remove(f.Name())
-	defer f.Close()
-
-	_, err = fmt.Fprintf(f, "%s", strings.TrimSpace(s.SetupScript))
-	if err != nil {
-		return errgo.Notef(err, "failed to write temporary script file")
+	var s *config.Session
+	if contents != nil {
+		s, err = config.LoadContents(path, contents)
+	} else {
+		s, err = config.Load(path)
 	}
-	err = f.Close()
 	if err != nil {
-		return errgo.Notef(err, "error closing temporary script file")
+		return nil, errgo.WithCausef(err, errParse, "")
 	}
-	err = os.Chmod(f.Name(), 0700)
+	s.ApplyConfig(conf)
+	err = s.ValidateLinks()
 	if err != nil {
-		return errgo.Notef(err, "error setting temporary script executable")
+		return nil, errgo.WithCausef(err, errParse, "")
 	}
-
-	c := exec.Command(f.Name())
-	c.Stdin = os.Stdin
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	return errgo.Mask(c.Run())
+	return s, nil
 }
 
-func (s *session) create() error {
-	if len(s.Windows) == 0 {
-		return errgo.New("no windows configured for this session!")
-	}
-	err := s.tmux("new-session", "-d", "-s", s.Name,
-		os.ExpandEnv(s.Windows[0].Command))
-	if err != nil {
-		return errgo.Notef(err, "failed to start tmux session")
-	}
-
-	var keys []string
-	for k, v := range s.Environment {
-		err = s.tmux("set-environment", "-t", s.Name, k, os.ExpandEnv(v))
+// runSetupScript runs the session's setup script, if it has one, and then
+// its windows' setup scripts. They're stopped if the session's setup
+// timeout runs out, or tmuxg is interrupted.
+func runSetupScript(s *config.Session) error {
+	ctx, cancel := setupContext(s)
+	defer cancel()
+	if s.SetupScript != "" {
+		c, cleanup, err := scriptCommand(s.SetupScript)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		defer cleanup()
+		c.Dir, err = setupCwd(s)
 		if err != nil {
-			return errgo.Notef(err, "warning: failed to set environment variable %q", k)
+			return errgo.Mask(err)
+		}
+		c.Env = s.Env()
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		err = runScript(ctx, c, true)
+		if err != nil {
+			return errgo.Mask(err)
 		}
-		keys = append(keys, k)
 	}
-
-	return nil
+	err := runWindowSetupScripts(ctx, s, localWindowScript(s))
+	if err != nil || !hasSetupScripts(s) {
+		return errgo.Mask(err)
+	}
+	return errgo.Mask(writeSetupState(s))
 }
 
-func (s *session) createWindow(i int, w *window) error {
-	cwd := w.Cwd
-	if cwd == "" {
-		cwd = s.Cwd
-	}
-	cwd = os.ExpandEnv(cwd)
-	err := s.tmux("new-window", "-d", "-t", fmt.Sprintf("%s:%d", s.Name, i),
-		"-c", cwd, os.ExpandEnv(w.Command))
+// scriptCommand writes script to a temporary executable file, and returns
+// a command that runs it, and a function that removes it.
+func scriptCommand(script string) (*exec.Cmd, func(), error) {
+	f, err := ioutil.TempFile("", "tmuxg-setup")
 	if err != nil {
-		return errgo.Notef(err, "failed to create window %q", w.Name)
+		return nil, nil, errgo.Notef(err, "failed to create temporary file for script")
 	}
-	return nil
-}
+	cleanup := func() { os.Remove(f.Name()) }
+	defer f.Close()
 
-func (s *session) sendKeys(w *window) error {
-	if len(w.Keystrokes) > 0 {
-		err := s.tmux(append([]string{"send-keys"}, w.Keystrokes...)...)
-		if err != nil {
-			return errgo.Notef(err, "failed to send keystrokes to window %q", w.Name)
-		}
+	_, err = fmt.Fprintf(f, "%s", strings.TrimSpace(script))
+	if err != nil {
+		cleanup()
+		return nil, nil, errgo.Notef(err, "failed to write temporary script file")
 	}
-	return nil
-}
-
-func (s *session) focus(i int) error {
-	err := s.tmux("select-window", "-t", fmt.Sprintf("%s:%d", s.Name, i))
+	err = f.Close()
 	if err != nil {
-		return errgo.Notef(err, "failed to set window focus")
+		cleanup()
+		return nil, nil, errgo.Notef(err, "error closing temporary script file")
 	}
-	return nil
+	err = os.Chmod(f.Name(), 0700)
+	if err != nil {
+		cleanup()
+		return nil, nil, errgo.Notef(err, "error setting temporary script executable")
+	}
+	return exec.Command(f.Name()), cleanup, nil
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// nameField matches the top-level name of a session file.
+var nameField = regexp.MustCompile(`(?m)^name:.*$`)
+
+// setSessionName returns the contents of a session file, with the session
+// renamed to name.
+func setSessionName(contents []byte, name string) []byte {
+	field := []byte("name: " + name)
+	if nameField.Match(contents) {
+		return nameField.ReplaceAllLiteral(contents, field)
+	}
+	return append(append(field, '\n'), contents...)
+}
+
+// cwdField matches a cwd in a session file, the session's or a window's.
+var cwdField = regexp.MustCompile(`(?m)^(\s*(?:- )?cwd:\s*)(.*)$`)
+
+// setCwdNames returns the contents of a session file, with the components
+// of its cwds that are oldName, as in ${HOME}/src/oldName, changed to
+// newName.
+func setCwdNames(contents []byte, oldName, newName string) []byte {
+	return cwdField.ReplaceAllFunc(contents, func(line []byte) []byte {
+		m := cwdField.FindSubmatch(line)
+		value, quote := string(m[2]), ""
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value, quote = value[1:len(value)-1], value[:1]
+		}
+		parts := strings.Split(value, "/")
+		for i, part := range parts {
+			if part == oldName {
+				parts[i] = newName
+			}
+		}
+		return []byte(string(m[1]) + quote + strings.Join(parts, "/") + quote)
+	})
+}
+
+// checkNewSession checks that name is free to name a new session, and
+// returns where its session file would go in the directory dir, with the
+// extension ext.
+func checkNewSession(dir, name, ext string) (string, error) {
+	if _, ok := commands[name]; ok {
+		return "", errgo.WithCausef(nil, errUsage, "%q is a tmuxg command, so it can't name a session", name)
+	}
+	if existing, err := config.Locate(name); err == nil {
+		return "", errgo.Newf("session %q already exists in %q", name, existing)
+	}
+	return filepath.Join(dir, name+ext), nil
+}
+
+// renameCommand renames a session: its session file, the name in it, and
+// the session itself if it's running.
+func renameCommand(conf *config.Config, args []string) error {
+	err := commandArgs("rename", args, 2)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	oldName, newName := args[0], args[1]
+	path, err := config.Locate(oldName)
+	if err != nil {
+		return errgo.WithCausef(err, errConfigNotFound, "")
+	}
+	newPath, err := checkNewSession(filepath.Dir(path), newName, filepath.Ext(path))
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, oldName)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if backend.Running(b) {
+		ts, ok := b.(*tmux.Session)
+		if !ok || ts.OwnsServer() {
+			// The server's socket is named after the session, and
+			// can't be renamed while it's running.
+			return errgo.Newf("session %q is running on a server of its own, stop it before renaming it", s.Name)
+		}
+		err = ts.Run("rename-session", "-t", s.Name, newName)
+		if err != nil {
+			return errgo.Notef(err, "failed to rename running session %q", s.Name)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errgo.Notef(err, "failed to read session file %q", path)
+	}
+	err = ioutil.WriteFile(path, setSessionName(contents, newName), 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write session file %q", path)
+	}
+	err = os.Rename(path, newPath)
+	if err != nil {
+		return errgo.Notef(err, "failed to rename session file %q", path)
+	}
+	// Carry over its setup state, so it isn't set up again.
+	oldState := setupStatePath(s)
+	s.Name = newName
+	if err := os.Rename(oldState, setupStatePath(s)); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to move setup state: %v", err)
+	}
+	log.Printf("renamed session %q to %q, in %q", oldName, newName, newPath)
+	return nil
+}
+
+// rmCommand removes a session's file from the tmuxg config directory, once
+// confirmed, and kills the session if asked to.
+func rmCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ContinueOnError)
+	force := fs.Bool("force", false, "don't ask for confirmation")
+	kill := fs.Bool("kill", false, "stop the session too, if it's running")
+	args, err := parseCommandFlags("rm", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("rm", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	var path string
+	for _, ext := range config.SessionExts {
+		if _, err := os.Lstat(filepath.Join(config.Dir(), args[0]+ext)); err == nil {
+			path = filepath.Join(config.Dir(), args[0]+ext)
+			break
+		}
+	}
+	if path == "" {
+		return errgo.WithCausef(nil, errConfigNotFound, "no session %q in %q", args[0], config.Dir())
+	}
+	s, err := loadSession(conf, path)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	if !*force {
+		fmt.Printf("Remove session file %q? [y/N] ", path)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return errgo.New("not removed")
+		}
+	}
+
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if backend.Running(b) {
+		if *kill {
+			err = stopSession(conf, s)
+			if err != nil {
+				return errgo.Mask(err, errgo.Any)
+			}
+		} else {
+			log.Printf("session %q is still running, use -kill to stop it too", s.Name)
+		}
+	}
+	// A session file linked from a project only has its link removed.
+	err = os.Remove(path)
+	if err != nil {
+		return errgo.Notef(err, "failed to remove session file %q", path)
+	}
+	if err := os.Remove(setupStatePath(s)); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove setup state: %v", err)
+	}
+	log.Printf("removed session %q", s.Name)
+	return nil
+}
+
+// cpCommand copies a session file to make a new session, renamed, along
+// with any cwd in it named after the session.
+func cpCommand(conf *config.Config, args []string) error {
+	err := commandArgs("cp", args, 2)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	src, dst := args[0], args[1]
+	path, err := config.Locate(src)
+	if err != nil {
+		return errgo.WithCausef(err, errConfigNotFound, "")
+	}
+	s, err := loadSession(conf, src)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	newPath, err := checkNewSession(config.Dir(), dst, filepath.Ext(path))
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errgo.Notef(err, "failed to read session file %q", path)
+	}
+	contents = setSessionName(contents, dst)
+	if s.Name != "" {
+		contents = setCwdNames(contents, s.Name, dst)
+	}
+	err = ioutil.WriteFile(newPath, contents, 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write session file %q", newPath)
+	}
+	if s.Dir != config.Dir() {
+		log.Printf("paths relative to %q in the copy are now relative to %q", s.Dir, config.Dir())
+	}
+	log.Printf("copied session %q to %q, in %q", s.Name, dst, newPath)
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestSetCwdNames(t *testing.T) {
+	contents := `name: api
+description: the api, and its api docs
+cwd: ${HOME}/src/api
+windows:
+  - name: api
+    cwd: "${HOME}/src/api/cmd/api-server"
+  - cwd: /srv/api
+    command: api serve
+`
+	want := `name: api
+description: the api, and its api docs
+cwd: ${HOME}/src/web
+windows:
+  - name: api
+    cwd: "${HOME}/src/web/cmd/api-server"
+  - cwd: /srv/web
+    command: api serve
+`
+	got := string(setCwdNames([]byte(contents), "api", "web"))
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// metrics are what the server has seen of the sessions it manages, for
+// Prometheus to scrape.
+type metrics struct {
+	setupSeconds  map[string]float64
+	setupCount    map[string]int
+	setupFailures map[string]int
+	failures      map[[2]string]int
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		setupSeconds:  make(map[string]float64),
+		setupCount:    make(map[string]int),
+		setupFailures: make(map[string]int),
+		failures:      make(map[[2]string]int),
+	}
+}
+
+// timeSetup returns setup, recording how long it takes and whether it
+// fails.
+func (m *metrics) timeSetup(setup func(*config.Session) error) func(*config.Session) error {
+	if setup == nil {
+		return nil
+	}
+	return func(s *config.Session) error {
+		start := time.Now()
+		err := setup(s)
+		m.setupSeconds[s.Name] += time.Since(start).Seconds()
+		m.setupCount[s.Name]++
+		if err != nil {
+			m.setupFailures[s.Name]++
+		}
+		return err
+	}
+}
+
+// failed records an API action on a session that failed.
+func (m *metrics) failed(session, action string) {
+	m.failures[[2]string{session, action}]++
+}
+
+// serveMetrics writes the metrics, and the state of every session, in the
+// Prometheus text format.
+func (srv *server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	sessions, err := srv.sessions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m := srv.metrics
+
+	running := 0
+	for _, s := range sessions {
+		if s.Running {
+			running++
+		}
+	}
+	writeMetric(w, "tmuxg_sessions_running", "gauge", "Sessions running.")
+	fmt.Fprintf(w, "tmuxg_sessions_running %d\n", running)
+
+	writeMetric(w, "tmuxg_session_up", "gauge", "Whether a session is running.")
+	for _, s := range sessions {
+		fmt.Fprintf(w, "tmuxg_session_up{session=%q} %d\n", s.Name, boolMetric(s.Running))
+	}
+	writeMetric(w, "tmuxg_session_windows", "gauge", "Windows in a running session.")
+	for _, s := range sessions {
+		if s.Running {
+			fmt.Fprintf(w, "tmuxg_session_windows{session=%q} %d\n", s.Name, len(s.Windows))
+		}
+	}
+
+	writeMetric(w, "tmuxg_setup_duration_seconds", "summary", "Time taken by setup scripts.")
+	for _, name := range sortedNames(m.setupCount) {
+		fmt.Fprintf(w, "tmuxg_setup_duration_seconds_sum{session=%q} %g\n", name, m.setupSeconds[name])
+		fmt.Fprintf(w, "tmuxg_setup_duration_seconds_count{session=%q} %d\n", name, m.setupCount[name])
+	}
+	writeMetric(w, "tmuxg_setup_failures_total", "counter", "Setup scripts that failed.")
+	for _, name := range sortedNames(m.setupFailures) {
+		fmt.Fprintf(w, "tmuxg_setup_failures_total{session=%q} %d\n", name, m.setupFailures[name])
+	}
+
+	writeMetric(w, "tmuxg_failures_total", "counter", "API actions on sessions that failed.")
+	var keys [][2]string
+	for k := range m.failures {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0] < keys[j][0] || keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "tmuxg_failures_total{session=%q,action=%q} %d\n", k[0], k[1], m.failures[k])
+	}
+}
+
+func writeMetric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedNames(m map[string]int) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
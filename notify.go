@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// desktopNotify pops up a desktop notification, with notify-send, or
+// osascript on macOS. Failing to is only logged, as the notification is a
+// nicety.
+func desktopNotify(title, message string) {
+	if err := desktopNotifyCommand(title, message).Run(); err != nil {
+		log.Printf("failed to send desktop notification: %v", err)
+	}
+}
+
+// desktopNotifyCommand returns the command that pops up a desktop
+// notification.
+func desktopNotifyCommand(title, message string) *exec.Cmd {
+	if runtime.GOOS == "darwin" {
+		return exec.Command("osascript", "-e",
+			"display notification "+strconv.Quote(message)+" with title "+strconv.Quote(title))
+	}
+	return exec.Command("notify-send", title, message)
+}
+
+// notifyEvent tells the session's notifiers about an event. Failing to is
+// only logged, so that notifications can't get in the way of the session.
+func notifyEvent(s *config.Session, event string) {
+	for _, n := range s.Notify {
+		if !n.Notifies(event) {
+			continue
+		}
+		var err error
+		switch {
+		case n.Command != "":
+			c := exec.Command("/bin/sh", "-c", n.Command)
+			c.Env = append(s.Env(), "TMUXG_EVENT="+event, "TMUXG_SESSION="+s.Name)
+			c.Stdout = os.Stderr
+			c.Stderr = os.Stderr
+			err = c.Run()
+		case n.Webhook != "":
+			host, _ := os.Hostname()
+			err = postJSON(s.ExpandEnv(n.Webhook), map[string]string{
+				"event":   event,
+				"session": s.Name,
+				"host":    host,
+				"user":    os.Getenv("USER"),
+				"time":    time.Now().UTC().Format(time.RFC3339),
+			})
+		case n.Slack != "":
+			host, _ := os.Hostname()
+			err = postJSON(s.ExpandEnv(n.Slack), map[string]string{
+				"text": fmt.Sprintf("tmuxg: %s %s on %s", s.Name, event, host),
+			})
+		}
+		if err != nil {
+			log.Printf("failed to notify of %s: %v", event, err)
+		}
+	}
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+func postJSON(url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	resp, err := notifyClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errgo.Newf("%s: %s", url, resp.Status)
+	}
+	return nil
+}
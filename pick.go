@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// pickCommand asks which session to start, most recently started first,
+// and starts it.
+func pickCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("pick", flag.ContinueOnError)
+	preview := fs.Bool("preview", false, "describe the session, for the picker's preview")
+	args, err := parseCommandFlags("pick", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if *preview {
+		err = commandArgs("pick", args, 1)
+		if err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
+		return errgo.Mask(previewByName(conf, os.Stdout, args[0]), errgo.Any)
+	}
+	err = commandArgs("pick", args, 0)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	names, err := sessionsByRecentUse()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if len(names) == 0 {
+		return errgo.WithCausef(nil, errConfigNotFound, "no sessions in %q", config.Dir())
+	}
+	var name string
+	if _, lookErr := exec.LookPath("fzf"); lookErr == nil {
+		name, err = pickWithFzf(names)
+	} else {
+		name, err = pickFromList(conf, names)
+	}
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if name == "" {
+		return errgo.New("no session picked")
+	}
+	return errgo.Mask(startSession(conf, name), errgo.Any)
+}
+
+// sessionsByRecentUse returns the names of the sessions in the tmuxg config
+// and shared directories, those started most recently first, then the rest
+// by name.
+func sessionsByRecentUse() ([]string, error) {
+	var paths []string
+	for _, dir := range []string{config.Dir(), config.SharedDir()} {
+		for _, ext := range config.SessionExts {
+			matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+			if err != nil {
+				return nil, errgo.Mask(err)
+			}
+			paths = append(paths, matches...)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+	rest := make(map[string]bool)
+	for _, path := range paths {
+		if filepath.Base(path) != "config.yaml" {
+			rest[sessionName(path)] = true
+		}
+	}
+	launches, err := recentLaunches()
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	var names []string
+	for _, l := range launches {
+		if rest[l.name] {
+			names = append(names, l.name)
+			delete(rest, l.name)
+		}
+	}
+	for _, path := range paths {
+		name := sessionName(path)
+		if rest[name] {
+			names = append(names, name)
+			delete(rest, name)
+		}
+	}
+	return names, nil
+}
+
+// pickWithFzf asks which session to start with fzf, previewing each.
+func pickWithFzf(names []string) (string, error) {
+	tmuxg, err := os.Executable()
+	if err != nil {
+		return "", errgo.Notef(err, "cannot find the tmuxg executable")
+	}
+	cmd := exec.Command("fzf", "--no-sort", "--prompt", "session> ",
+		"--preview", config.ShellQuote(tmuxg)+" pick -preview {}")
+	cmd.Stdin = strings.NewReader(strings.Join(names, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errgo.New("no session picked")
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// pickFromList asks which session to start from a numbered list, with each
+// session's preview.
+func pickFromList(conf *config.Config, names []string) (string, error) {
+	for i, name := range names {
+		fmt.Printf("%d) %s\n", i+1, name)
+		var buf strings.Builder
+		err := previewByName(conf, &buf, name)
+		if err != nil {
+			fmt.Printf("   %v\n", err)
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			fmt.Printf("   %s\n", line)
+		}
+	}
+	w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	answer, err := w.ask("Session", "1", func(answer string) error {
+		if n, err := strconv.Atoi(answer); err != nil || n < 1 || n > len(names) {
+			return errgo.Newf("pick a number from 1 to %d", len(names))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	n, _ := strconv.Atoi(answer)
+	return names[n-1], nil
+}
+
+// previewByName describes the named session, as previewSession does, unless
+// its session file computes it, which isn't worth running just to preview.
+func previewByName(conf *config.Config, w io.Writer, name string) error {
+	path, err := config.Locate(name)
+	if err != nil {
+		return errgo.WithCausef(err, errConfigNotFound, "")
+	}
+	if config.Computed(path) {
+		fmt.Fprintf(w, "computed by %s\n", path)
+		return nil
+	}
+	s, err := loadSession(conf, path)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	previewSession(w, s)
+	return nil
+}
+
+// previewSession describes the session briefly: what it's for, where it
+// starts and its windows.
+func previewSession(w io.Writer, s *config.Session) {
+	if s.Description != "" {
+		fmt.Fprintln(w, s.Description)
+	}
+	if s.Cwd != "" {
+		fmt.Fprintf(w, "cwd: %s\n", s.ExpandEnv(s.Cwd))
+	}
+	for _, win := range s.Windows {
+		fmt.Fprintf(w, "  %-16s %s\n", win.Name, win.Command)
+	}
+}
+
+// sessionName returns the name of the session in the tmuxg config or shared
+// directory with the session file at path.
+func sessionName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
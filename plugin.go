@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// pluginPrefix starts the names of executables that add subcommands to
+// tmuxg: tmuxg foo runs tmuxg-foo.
+const pluginPrefix = "tmuxg-"
+
+// pluginContext is what a plugin is told about the tmuxg running it, as
+// JSON in $TMUXG_CONTEXT.
+type pluginContext struct {
+	Tmuxg       string `json:"tmuxg"`
+	Version     string `json:"version"`
+	ConfigDir   string `json:"config_dir"`
+	SharedDir   string `json:"shared_dir"`
+	TmuxBin     string `json:"tmux_bin"`
+	SessionFile string `json:"session_file,omitempty"`
+	Session     string `json:"session,omitempty"`
+}
+
+// findPlugin returns the path of the plugin for subcommand name, if there's
+// one on $PATH. A session named name takes precedence.
+func findPlugin(name string) (string, bool) {
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	if _, err := config.Locate(name); !os.IsNotExist(err) {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	return path, err == nil
+}
+
+// runPlugin runs a plugin with args, as tmuxg-foo args..., and fails with
+// its exit status, as a pluginExit, if it does. It's told about tmuxg, and
+// the session of the project it's run in, if any, in its environment.
+func runPlugin(conf *config.Config, path string, args []string) error {
+	ctx := pluginContext{
+		Version:   version,
+		ConfigDir: config.Dir(),
+		SharedDir: config.SharedDir(),
+		TmuxBin:   conf.Tmux(),
+	}
+	if exe, err := os.Executable(); err == nil {
+		ctx.Tmuxg = exe
+	}
+	if local := findLocalSession(); local != "" {
+		ctx.SessionFile = local
+		if s, err := loadSession(conf, local); err == nil {
+			ctx.Session = s.Name
+		}
+	}
+	contextJSON, err := json.Marshal(ctx)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	c := exec.Command(path, args...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = append(os.Environ(),
+		"TMUXG="+ctx.Tmuxg,
+		"TMUXG_VERSION="+ctx.Version,
+		"TMUXG_CONFIG_DIR="+ctx.ConfigDir,
+		"TMUXG_SESSION_FILE="+ctx.SessionFile,
+		"TMUXG_SESSION="+ctx.Session,
+		"TMUXG_CONTEXT="+string(contextJSON),
+	)
+	err = c.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+		return errgo.WithCausef(nil, pluginExit(exitErr.ExitCode()), "plugin %q exited with status %d", path, exitErr.ExitCode())
+	}
+	if err != nil {
+		return errgo.Notef(err, "failed to run plugin %q", path)
+	}
+	return nil
+}
+
+// plugins returns the names of the subcommands plugins on $PATH add.
+func plugins() []string {
+	found := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		matches, _ := filepath.Glob(filepath.Join(dir, pluginPrefix+"*"))
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+				found[strings.TrimPrefix(filepath.Base(path), pluginPrefix)] = true
+			}
+		}
+	}
+	var names []string
+	for name := range found {
+		if _, ok := commands[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
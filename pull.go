@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// pullCommand clones or updates the shared session files from the
+// repository set as shared-sessions in config.yaml.
+func pullCommand(conf *config.Config, args []string) error {
+	err := commandArgs("pull", args, 0)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if conf.SharedSessions == "" {
+		return errgo.WithCausef(nil, errUsage, "set shared-sessions in %q to a git repository of session files",
+			filepath.Join(config.Dir(), "config.yaml"))
+	}
+
+	dir := config.SharedDir()
+	var c *exec.Cmd
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		origin, _ := git(dir, "config", "--get", "remote.origin.url")
+		if url := strings.TrimSpace(string(origin)); url != conf.SharedSessions {
+			return errgo.Newf("%q is a copy of %q, not %q; remove it to pull from shared-sessions", dir, url, conf.SharedSessions)
+		}
+		c = exec.Command("git", "-C", dir, "pull", "--ff-only", "--quiet")
+	} else {
+		c = exec.Command("git", "clone", "--quiet", conf.SharedSessions, dir)
+	}
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	log.Printf("%v", c)
+	err = c.Run()
+	if err != nil {
+		return errgo.Notef(err, "failed to pull shared sessions from %q", conf.SharedSessions)
+	}
+	return nil
+}
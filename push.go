@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// remoteConfigDir is the tmuxg config directory on another host, as a shell
+// expression.
+const remoteConfigDir = `"${XDG_CONFIG_HOME:-$HOME/.config}/tmuxg"`
+
+// pushCommand installs a session file, and the files it refers to, into the
+// tmuxg config directory of other hosts.
+func pushCommand(conf *config.Config, args []string) error {
+	if len(args) < 2 {
+		return errgo.Mask(commandArgs("push", args, 2), errgo.Any)
+	}
+	path, err := config.Locate(args[0])
+	if err != nil {
+		return errgo.WithCausef(err, errConfigNotFound, "")
+	}
+	s, err := loadSession(conf, path)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	files, err := sessionFiles(path, s)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, host := range args[1:] {
+		err := pushFiles(host, files)
+		if err != nil {
+			return errgo.Notef(err, "failed to push session %q to %q", s.Name, host)
+		}
+		fmt.Printf("pushed %s to %s\n", s.Name, host)
+	}
+	return nil
+}
+
+// sessionFiles returns the contents of the session file at path and the
+// files it refers to, by their paths relative to the config directory.
+func sessionFiles(path string, s *config.Session) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read session file %q", path)
+	}
+	files[s.Name+filepath.Ext(path)] = contents
+
+	if s.TmuxConfig != "" {
+		tmuxConfig := s.ExpandEnv(s.TmuxConfig)
+		rel := filepath.Clean(tmuxConfig)
+		if filepath.IsAbs(tmuxConfig) {
+			log.Printf("not pushing tmux-config %q, as it isn't relative to the session file", tmuxConfig)
+		} else if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			log.Printf("not pushing tmux-config %q, as it's outside the session file's directory", tmuxConfig)
+		} else {
+			contents, err := ioutil.ReadFile(filepath.Join(s.Dir, tmuxConfig))
+			if err != nil {
+				return nil, errgo.Notef(err, "failed to read tmux-config %q", tmuxConfig)
+			}
+			files[rel] = contents
+		}
+	}
+	return files, nil
+}
+
+// pushFiles writes files into the tmuxg config directory on host.
+func pushFiles(host string, files map[string][]byte) error {
+	h, err := dialSSH(host)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer h.Close()
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dest := remoteConfigDir + "/" + config.ShellQuote(filepath.ToSlash(name))
+		c := h.command(false, `mkdir -p "$(dirname `+dest+`)" && cat > `+dest)
+		c.Stdin = bytes.NewReader(files[name])
+		c.Stderr = os.Stderr
+		err := c.Run()
+		if err != nil {
+			return errgo.Notef(err, "failed to write %q", name)
+		}
+	}
+	return nil
+}
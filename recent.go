@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// launch is a session tmuxg started, and when it last did.
+type launch struct {
+	name string
+	path string
+	at   time.Time
+}
+
+// launchesDir holds a file for each session tmuxg has started, naming its
+// session file, and modified when it was last started.
+func launchesDir() string {
+	return filepath.Join(stateDir(), "launched")
+}
+
+// recordLaunch records that the session in the session file at path is
+// being started. Failing to record it isn't worth failing the start for.
+func recordLaunch(s *config.Session, path string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	dir := launchesDir()
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(dir, s.Name), []byte(path+"\n"), 0600)
+	}
+	if err != nil {
+		log.Printf("failed to record starting session %q: %v", s.Name, err)
+	}
+}
+
+// recentLaunches returns the sessions tmuxg has started whose session files
+// are still there, most recently started first.
+func recentLaunches() ([]launch, error) {
+	infos, err := ioutil.ReadDir(launchesDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errgo.Notef(err, "failed to read %q", launchesDir())
+	}
+	var launches []launch
+	for _, info := range infos {
+		contents, err := ioutil.ReadFile(filepath.Join(launchesDir(), info.Name()))
+		if err != nil {
+			continue
+		}
+		path := strings.TrimSpace(string(contents))
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		launches = append(launches, launch{name: info.Name(), path: path, at: info.ModTime()})
+	}
+	sort.Slice(launches, func(i, j int) bool {
+		return launches[i].at.After(launches[j].at)
+	})
+	return launches, nil
+}
+
+// printRecent lists the sessions tmuxg has started, most recently started
+// first.
+func printRecent() error {
+	launches, err := recentLaunches()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, l := range launches {
+		fmt.Printf("%-20s %s\n", l.name, l.at.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// lastCommand starts the session tmuxg last started again, and attaches
+// to it.
+func lastCommand(conf *config.Config, args []string) error {
+	err := commandArgs("last", args, 0)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	launches, err := recentLaunches()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if len(launches) == 0 {
+		return errgo.WithCausef(nil, errConfigNotFound, "no session has been started yet")
+	}
+	return errgo.Mask(startSession(conf, launches[0].path), errgo.Any)
+}
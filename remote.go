@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// startCommand starts a session, here or on another host over ssh.
+func startCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	host := fs.String("host", "", "start the session on this host, as given to ssh, such as user@dev-box")
+	transport := fs.String("transport", "ssh", "attach to a session on another host over ssh or mosh")
+	args, err := parseCommandFlags("start", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("start", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if *transport != "ssh" && *transport != "mosh" {
+		return errgo.WithCausef(nil, errUsage, "unknown transport %q, use ssh or mosh", *transport)
+	}
+	if *host == "" {
+		return errgo.Mask(startSession(conf, args[0]), errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	return errgo.Mask(startRemote(s, *host, *transport == "mosh"), errgo.Any)
+}
+
+// sshHost is a connection to another host, shared by every ssh command run
+// on it.
+type sshHost struct {
+	host        string
+	controlPath string
+}
+
+// dialSSH opens a master connection to host.
+func dialSSH(host string) (*sshHost, error) {
+	dir := runtimeDir()
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to create runtime directory %q", dir)
+	}
+	h := &sshHost{
+		host:        host,
+		controlPath: filepath.Join(dir, "ssh-"+host),
+	}
+	c := exec.Command("ssh", "-M", "-N", "-f", "-S", h.controlPath, host)
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+	err = c.Run()
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to connect to %q", host)
+	}
+	return h, nil
+}
+
+// command returns an ssh command running script on the host.
+func (h *sshHost) command(tty bool, script string) *exec.Cmd {
+	args := []string{"-S", h.controlPath}
+	if tty {
+		args = append(args, "-q", "-t")
+	}
+	return exec.Command("ssh", append(args, h.host, script)...)
+}
+
+// Close closes the master connection.
+func (h *sshHost) Close() error {
+	return errgo.Mask(exec.Command("ssh", "-S", h.controlPath, "-O", "exit", h.host).Run())
+}
+
+// env returns the host's login environment.
+func (h *sshHost) env() (map[string]string, error) {
+	out, err := h.command(false, "env").Output()
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read environment of %q", h.host)
+	}
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if i := strings.Index(scanner.Text(), "="); i > 0 {
+			env[scanner.Text()[:i]] = scanner.Text()[i+1:]
+		}
+	}
+	return env, nil
+}
+
+// isDir returns whether dir is a directory on the host.
+func (h *sshHost) isDir(dir string) bool {
+	return h.command(false, "test -d "+config.ShellQuote(dir)).Run() == nil
+}
+
+// hasMosh returns whether mosh is installed here, and its server on the
+// host.
+func (h *sshHost) hasMosh() bool {
+	if _, err := exec.LookPath("mosh"); err != nil {
+		return false
+	}
+	return h.command(false, "command -v mosh-server").Run() == nil
+}
+
+// scriptCommand returns an ssh command running script on the host, with
+// the session's environment, after cd, a shell command changing to the
+// directory it runs in, if that's set.
+func (h *sshHost) scriptCommand(tty bool, s *config.Session, cd, script string) *exec.Cmd {
+	var keys []string
+	for k := range s.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var run string
+	for _, k := range keys {
+		run += "export " + k + "=" + config.ShellQuote(s.Getenv(k)) + "; "
+	}
+	if cd != "" {
+		run += cd + "; "
+	}
+	return h.command(tty, run+`f=$(mktemp) && printf '%s' `+config.ShellQuote(strings.TrimSpace(script))+` > "$f" && chmod 700 "$f" || exit 1; "$f"; r=$?; rm -f "$f"; exit $r`)
+}
+
+// runSetupScript runs the session's setup script on the host, connected to
+// the terminal, and then its windows' setup scripts, as runSetupScript does
+// here.
+func (h *sshHost) runSetupScript(s *config.Session) error {
+	ctx, cancel := setupContext(s)
+	defer cancel()
+	if s.SetupScript != "" {
+		dir := config.ShellQuote(setupDir(s, h.isDir))
+		c := h.scriptCommand(true, s, "mkdir -p "+dir+" && cd "+dir+" || exit 1", s.SetupScript)
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		err := runScript(ctx, c, true)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	return errgo.Mask(runWindowSetupScripts(ctx, s, func(w *config.Window) (*exec.Cmd, func(), error) {
+		var cd string
+		if cwd := backend.WindowCwd(s, w); cwd != "" {
+			cd = "cd " + config.ShellQuote(cwd) + " 2>/dev/null"
+		}
+		return h.scriptCommand(false, s, cd, w.SetupScript), func() {}, nil
+	}))
+}
+
+// startRemote starts the session with tmux on host, and attaches to it. The
+// session file is resolved here, against the host's home directory and
+// user, and tmux on the host is driven over a single ssh connection. With
+// mosh, the terminal is attached over mosh rather than ssh, if it's
+// installed at both ends.
+func startRemote(s *config.Session, host string, mosh bool) error {
+	if s.Backend != "" && s.Backend != config.BackendTmux {
+		return errgo.WithCausef(nil, errParse, "only tmux sessions can be started on another host")
+	}
+	if s.Compose != nil {
+		return errgo.WithCausef(nil, errParse, "sessions with a compose project can't be started on another host")
+	}
+	h, err := dialSSH(host)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer h.Close()
+
+	env, err := h.env()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	s.HostEnv = make(map[string]string)
+	for _, k := range []string{"HOME", "USER", "LOGNAME"} {
+		if v, ok := env[k]; ok {
+			s.HostEnv[k] = v
+		}
+	}
+	debugEnvironment(s)
+
+	session := tmux.New(s, "tmux")
+	runner := &tmux.SSHRunner{Host: host, Bin: "tmux", ControlPath: h.controlPath}
+	session.Runner = runner
+	if mosh {
+		if h.hasMosh() {
+			session.AttachRunner = &tmux.MoshRunner{SSHRunner: runner}
+		} else {
+			log.Printf("mosh isn't installed both here and on %q, attaching over ssh", host)
+		}
+	}
+	err = session.CheckRequirements()
+	if errgo.Cause(err) == tmux.ErrRequirement {
+		return errgo.WithCausef(err, errParse, "")
+	} else if err != nil {
+		return errgo.Mask(err, errgo.Is(tmux.ErrVersion))
+	}
+	var setup func(*config.Session) error
+	if *setupFlag || !h.isDir(s.ExpandEnv(s.Cwd)) {
+		setup = h.runSetupScript
+	}
+	return errgo.Mask(startBackend(session, s, s.Name+"@"+host, setup), errgo.Any)
+}
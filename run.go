@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// runCommand types a command into a window of a running session, or into
+// every window's shell, and presses Enter. Flags end at the session, so
+// that the command's own are left alone; the command may also follow --.
+func runCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	all := fs.Bool("all", false, "run the command in every window's shell")
+	args, err := parseLeadingFlags("run", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	n := 2
+	if *all {
+		n = 1
+	}
+	var command []string
+	for i, arg := range args {
+		if arg == "--" {
+			args, command = args[:i], args[i+1:]
+			break
+		}
+	}
+	if len(command) == 0 && len(args) > n {
+		args, command = args[:n], args[n:]
+	}
+	if len(command) == 0 {
+		return errgo.Mask(commandArgs("run", nil, n), errgo.Any)
+	}
+	err = commandArgs("run", args, n)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	session, ok := b.(*tmux.Session)
+	if !ok {
+		return errgo.Newf("running commands needs the tmux backend")
+	}
+	if !backend.Running(b) {
+		return errgo.Newf("session %q is not running", s.Name)
+	}
+	if *all {
+		return errgo.Mask(broadcast(session, strings.Join(command, " ")))
+	}
+	return errgo.Mask(typeCommand(session, s.Name+":"+args[1], strings.Join(command, " ")))
+}
+
+// shells are the commands taken for shells, which are safe to type
+// commands into.
+var shells = map[string]bool{
+	"bash": true, "dash": true, "fish": true, "ksh": true,
+	"sh": true, "tcsh": true, "zsh": true,
+}
+
+// broadcast types command into every window of the session that's running
+// a shell, and presses Enter. Windows running anything else are left alone,
+// as the command would be typed into, say, an editor.
+func broadcast(session *tmux.Session, command string) error {
+	out, err := session.Output("list-windows", "-t", session.Name,
+		"-F", "#{window_index} #{pane_current_command} #{window_name}")
+	if err != nil {
+		return errgo.Notef(err, "failed to list windows of session %q", session.Name)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		if !shells[fields[1]] {
+			log.Printf("not running command in window %q, which is running %s", fields[2], fields[1])
+			continue
+		}
+		err := typeCommand(session, session.Name+":"+fields[0], command)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	return nil
+}
+
+// typeCommand types command into the window or pane target, and presses
+// Enter.
+func typeCommand(session *tmux.Session, target, command string) error {
+	// Typed literally, so that words like Enter in the command aren't
+	// taken for keys.
+	err := session.SendText(target, command)
+	if err == nil {
+		err = session.SendKeys(target, []string{"Enter"})
+	}
+	if err == nil {
+		err = session.Flush()
+	}
+	if err != nil {
+		return errgo.Notef(err, "failed to run command in %q", target)
+	}
+	return nil
+}
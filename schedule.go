@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// systemdScheduledUnit is the systemd user unit that a timer activates to
+// start a session. It's given the same as systemdUnit. The session's tmux
+// server outlives the unit, so only tmuxg itself is stopped with it.
+const systemdScheduledUnit = `[Unit]
+Description=tmuxg session %[1]s, on schedule
+
+[Service]
+Type=oneshot
+KillMode=process
+Environment=%[4]s
+ExecStart=%[2]s -no-attach start %[3]s
+`
+
+// systemdTimer is the systemd user timer that starts a session on
+// schedule, given the session's name and when to start it.
+const systemdTimer = `[Unit]
+Description=Start tmuxg session %[1]s on schedule
+
+[Timer]
+OnCalendar=%[2]s
+
+[Install]
+WantedBy=timers.target
+`
+
+// launchdScheduledAgent is the launchd user agent that starts a session on
+// schedule. It's given the same as launchdAgent, and the agent's calendar
+// intervals.
+const launchdScheduledAgent = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%[1]s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%[2]s</string>
+		<string>-no-attach</string>
+		<string>start</string>
+		<string>%[3]s</string>
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>PATH</key>
+		<string>%[4]s</string>
+	</dict>
+	<key>StartCalendarInterval</key>
+	<array>
+%[5]s	</array>
+</dict>
+</plist>
+`
+
+// scheduleCommand starts a session in the background at a time of day,
+// using a systemd timer, or a launchd user agent on macOS.
+func scheduleCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ContinueOnError)
+	at := fs.String("at", "", "time of day to start the session, as HH:MM")
+	weekdays := fs.Bool("weekdays", false, "only start the session Monday to Friday")
+	remove := fs.Bool("remove", false, "remove the session's schedule")
+	args, err := parseCommandFlags("schedule", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("schedule", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, path, err := serviceSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if *remove {
+		if runtime.GOOS == "darwin" {
+			return errgo.Mask(uninstallLaunchdSchedule(s.Name))
+		}
+		return errgo.Mask(uninstallSystemdTimer(s.Name))
+	}
+
+	t, err := time.Parse("15:04", *at)
+	if err != nil {
+		return errgo.WithCausef(nil, errUsage, "-at must be a time of day, as HH:MM")
+	}
+	tmuxg, err := os.Executable()
+	if err != nil {
+		return errgo.Notef(err, "cannot find the tmuxg executable")
+	}
+	if runtime.GOOS == "darwin" {
+		return errgo.Mask(installLaunchdSchedule(s.Name, tmuxg, path, t, *weekdays))
+	}
+	return errgo.Mask(installSystemdTimer(s.Name, tmuxg, path, t, *weekdays))
+}
+
+func installSystemdTimer(name, tmuxg, path string, t time.Time, weekdays bool) error {
+	unitPath := systemdUnitPath(name + "-schedule")
+	err := os.MkdirAll(filepath.Dir(unitPath), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create systemd user unit directory")
+	}
+	unit := systemdUnitFile(systemdScheduledUnit, name, tmuxg, path)
+	err = ioutil.WriteFile(unitPath, []byte(unit), 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write systemd unit %q", unitPath)
+	}
+
+	calendar := "*-*-* " + t.Format("15:04") + ":00"
+	if weekdays {
+		calendar = "Mon..Fri " + calendar
+	}
+	timerPath := systemdTimerPath(name)
+	timer := fmt.Sprintf(systemdTimer, name, calendar)
+	err = ioutil.WriteFile(timerPath, []byte(timer), 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write systemd timer %q", timerPath)
+	}
+	for _, args := range [][]string{
+		{"--user", "daemon-reload"},
+		{"--user", "enable", "--now", filepath.Base(timerPath)},
+	} {
+		err = systemctl(args...)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	fmt.Printf("installed %s\n", timerPath)
+	return nil
+}
+
+func uninstallSystemdTimer(name string) error {
+	timerPath := systemdTimerPath(name)
+	if _, err := os.Stat(timerPath); err != nil {
+		return errgo.Newf("session %q has no schedule", name)
+	}
+	err := systemctl("--user", "disable", "--now", filepath.Base(timerPath))
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, path := range []string{timerPath, systemdUnitPath(name + "-schedule")} {
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return errgo.Notef(err, "failed to remove %q", path)
+		}
+	}
+	err = systemctl("--user", "daemon-reload")
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	fmt.Printf("removed %s\n", timerPath)
+	return nil
+}
+
+// systemdTimerPath returns the path of the systemd user timer for the named
+// session.
+func systemdTimerPath(name string) string {
+	return strings.TrimSuffix(systemdUnitPath(name+"-schedule"), ".service") + ".timer"
+}
+
+func installLaunchdSchedule(name, tmuxg, path string, t time.Time, weekdays bool) error {
+	label := launchdLabel(name) + ".schedule"
+	agentPath := launchdAgentPath(name + ".schedule")
+	err := os.MkdirAll(filepath.Dir(agentPath), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create launch agent directory")
+	}
+
+	days := []int{-1}
+	if weekdays {
+		days = []int{1, 2, 3, 4, 5}
+	}
+	var intervals strings.Builder
+	for _, day := range days {
+		intervals.WriteString("\t\t<dict>\n")
+		if day >= 0 {
+			fmt.Fprintf(&intervals, "\t\t\t<key>Weekday</key>\n\t\t\t<integer>%d</integer>\n", day)
+		}
+		fmt.Fprintf(&intervals, "\t\t\t<key>Hour</key>\n\t\t\t<integer>%d</integer>\n", t.Hour())
+		fmt.Fprintf(&intervals, "\t\t\t<key>Minute</key>\n\t\t\t<integer>%d</integer>\n", t.Minute())
+		intervals.WriteString("\t\t</dict>\n")
+	}
+	agent := fmt.Sprintf(launchdScheduledAgent, html.EscapeString(label),
+		html.EscapeString(tmuxg), html.EscapeString(path), html.EscapeString(os.Getenv("PATH")),
+		intervals.String())
+	err = ioutil.WriteFile(agentPath, []byte(agent), 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write launch agent %q", agentPath)
+	}
+	err = launchctl("load", "-w", agentPath)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	fmt.Printf("installed %s\n", agentPath)
+	return nil
+}
+
+func uninstallLaunchdSchedule(name string) error {
+	agentPath := launchdAgentPath(name + ".schedule")
+	if _, err := os.Stat(agentPath); err != nil {
+		return errgo.Newf("session %q has no schedule", name)
+	}
+	err := launchctl("unload", "-w", agentPath)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = os.Remove(agentPath)
+	if err != nil {
+		return errgo.Notef(err, "failed to remove launch agent %q", agentPath)
+	}
+	fmt.Printf("removed %s\n", agentPath)
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// latestReleaseURL is where the latest release of tmuxg is described.
+const latestReleaseURL = "https://api.github.com/repos/cmars/tmuxg/releases/latest"
+
+// releaseKey is the minisign public key release checksums are signed with,
+// set by the release build with -ldflags "-X main.releaseKey=<key>".
+var releaseKey string
+
+// release is a GitHub release, and the files attached to it.
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// asset returns the download URL of the named file attached to the
+// release, or "" if it has none.
+func (r *release) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// selfUpdateCommand replaces the tmuxg executable with the latest release
+// for this platform, after checking it against the release's checksums, and
+// their signature.
+func selfUpdateCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	key := fs.String("key", "", "minisign public key file the release's checksums must be signed by, instead of the release key")
+	insecure := fs.Bool("insecure", false, "don't check the signature of the release's checksums")
+	force := fs.Bool("force", false, "update even if this is already the latest release")
+	args, err := parseCommandFlags("self-update", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("self-update", args, 0)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	r, err := latestRelease()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if r.Tag == version && !*force {
+		fmt.Printf("tmuxg %s is the latest release\n", version)
+		return nil
+	}
+	binary := fmt.Sprintf("tmuxg_%s_%s", runtime.GOOS, runtime.GOARCH)
+	binaryURL, sumsURL := r.asset(binary), r.asset("SHA256SUMS")
+	if binaryURL == "" {
+		return errgo.Newf("release %s has no %s", r.Tag, binary)
+	}
+	if sumsURL == "" {
+		return errgo.Newf("release %s has no SHA256SUMS to check %s against", r.Tag, binary)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return errgo.Notef(err, "cannot find the tmuxg executable")
+	}
+	if real, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = real
+	}
+	dir, err := ioutil.TempDir(filepath.Dir(exe), ".tmuxg-update")
+	if err != nil {
+		return errgo.Notef(err, "cannot write next to %q", exe)
+	}
+	defer os.RemoveAll(dir)
+
+	sums := filepath.Join(dir, "SHA256SUMS")
+	err = download(sumsURL, sums)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if *insecure {
+		log.Printf("not checking the signature of release %s's checksums", r.Tag)
+	} else {
+		err = verifyRelease(r, sums, *key)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	want, err := checksumOf(sums, binary)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+
+	path := filepath.Join(dir, binary)
+	err = download(binaryURL, path)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	got, err := sha256File(path)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if got != want {
+		return errgo.Newf("%s of release %s doesn't match its checksum", binary, r.Tag)
+	}
+	err = os.Chmod(path, 0755)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = os.Rename(path, exe)
+	if err != nil {
+		return errgo.Notef(err, "failed to replace %q", exe)
+	}
+	fmt.Printf("updated tmuxg from %s to %s\n", version, r.Tag)
+	return nil
+}
+
+// verifyRelease checks that the release's checksums, downloaded to sums,
+// are signed by the minisign public key file key, or without one, by the
+// release key.
+func verifyRelease(r *release, sums, key string) error {
+	if key == "" {
+		if releaseKey == "" {
+			return errgo.New("this tmuxg wasn't built with a release key, so give one with -key, or skip checking signatures with -insecure")
+		}
+		key = filepath.Join(filepath.Dir(sums), "release.pub")
+		err := ioutil.WriteFile(key, []byte("untrusted comment: tmuxg release key\n"+releaseKey+"\n"), 0644)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	sigURL := r.asset("SHA256SUMS.minisig")
+	if sigURL == "" {
+		return errgo.Newf("release %s has no SHA256SUMS.minisig to check its checksums against", r.Tag)
+	}
+	err := download(sigURL, sums+".minisig")
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = verifyMinisign(key, sums)
+	if err != nil {
+		return errgo.Notef(err, "release %s's checksums aren't signed by %q", r.Tag, key)
+	}
+	return nil
+}
+
+// latestRelease describes the latest release of tmuxg.
+func latestRelease() (*release, error) {
+	resp, err := httpClient.Get(latestReleaseURL)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to find the latest release")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errgo.Newf("failed to find the latest release: %s", resp.Status)
+	}
+	var r release
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read the latest release")
+	}
+	return &r, nil
+}
+
+// checksumOf returns the checksum of the named file in a sha256sum listing.
+func checksumOf(sums, name string) (string, error) {
+	f, err := os.Open(sums)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errgo.Mask(err)
+	}
+	return "", errgo.Newf("SHA256SUMS has no checksum for %s", name)
+}
+
+// sha256File returns the hex SHA-256 checksum of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// server serves tmuxg's local API, with which editors, launchers and window
+// manager scripts can control sessions.
+//
+//	GET  /sessions               list sessions
+//	GET  /sessions/<name>        a session's status
+//	POST /sessions/<name>/start  start a session, without attaching
+//	POST /sessions/<name>/kill   stop a session, as tmuxg stop does
+//	POST /sessions/<name>/apply  rebuild a session from its file
+type server struct {
+	conf    *config.Config
+	metrics *metrics
+
+	// mu serializes requests, as starting a session changes tmuxg's
+	// environment.
+	mu sync.Mutex
+}
+
+// sessionStatus is a session, as the API describes it.
+type sessionStatus struct {
+	Name    string   `json:"name"`
+	Running bool     `json:"running"`
+	Windows []string `json:"windows,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// serveCommand serves the local API on a unix socket until interrupted.
+func serveCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", filepath.Join(runtimeDir(), "tmuxg.sock"), "unix socket to serve the API on")
+	metricsAddr := fs.String("metrics", "", "also serve Prometheus metrics over HTTP on this address, such as localhost:9182")
+	args, err := parseCommandFlags("serve", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("serve", args, 0)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+
+	err = os.MkdirAll(filepath.Dir(*listen), 0700)
+	if err != nil {
+		return errgo.Notef(err, "failed to create directory for %q", *listen)
+	}
+	// A socket left behind by a server that's gone would stop us listening.
+	if c, err := net.Dial("unix", *listen); err == nil {
+		c.Close()
+		return errgo.Newf("another tmuxg is already serving on %q", *listen)
+	}
+	os.Remove(*listen)
+	l, err := net.Listen("unix", *listen)
+	if err != nil {
+		return errgo.Notef(err, "failed to listen on %q", *listen)
+	}
+	defer os.Remove(*listen)
+
+	// Sessions started by the server run in the background.
+	*noAttachFlag = true
+	srv := &server{conf: conf, metrics: newMetrics()}
+	if *metricsAddr != "" {
+		ml, err := net.Listen("tcp", *metricsAddr)
+		if err != nil {
+			return errgo.Notef(err, "failed to listen on %q", *metricsAddr)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", srv.serveMetrics)
+		go func() {
+			log.Printf("metrics server stopped: %v", http.Serve(ml, mux))
+		}()
+		log.Printf("serving metrics on %s", ml.Addr())
+	}
+	log.Printf("serving on %s", *listen)
+	return errgo.Mask(http.Serve(l, srv))
+}
+
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "sessions" && r.Method == "GET":
+		srv.list(w)
+	case len(parts) == 2 && parts[0] == "sessions" && r.Method == "GET":
+		srv.status(w, parts[1])
+	case len(parts) == 3 && parts[0] == "sessions" && r.Method == "POST":
+		srv.action(w, parts[1], parts[2])
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func (srv *server) list(w http.ResponseWriter) {
+	sessions, err := srv.sessions()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// sessions describes the sessions in the tmuxg config directory.
+func (srv *server) sessions() ([]sessionStatus, error) {
+	var paths []string
+	for _, ext := range config.SessionExts {
+		matches, err := filepath.Glob(filepath.Join(config.Dir(), "*"+ext))
+		if err != nil {
+			return nil, errgo.Mask(err)
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	sessions := []sessionStatus{}
+	for _, path := range paths {
+		if filepath.Base(path) == "config.yaml" {
+			continue
+		}
+		status, _ := srv.sessionStatus(sessionName(path))
+		sessions = append(sessions, *status)
+	}
+	return sessions, nil
+}
+
+func (srv *server) status(w http.ResponseWriter, name string) {
+	status, err := srv.sessionStatus(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// sessionStatus describes the named session. A session that can't be
+// queried is described with the error, which is also returned.
+func (srv *server) sessionStatus(name string) (*sessionStatus, error) {
+	status := &sessionStatus{Name: name}
+	s, err := loadSession(srv.conf, name)
+	if err != nil {
+		status.Error = err.Error()
+		return status, errgo.Mask(err, errgo.Any)
+	}
+	b, err := newBackend(srv.conf, s)
+	if err != nil {
+		status.Error = err.Error()
+		return status, errgo.Mask(err, errgo.Any)
+	}
+	state, err := b.Query()
+	if err != nil {
+		status.Error = err.Error()
+		return status, errgo.Mask(err)
+	}
+	status.Running = state.Running
+	status.Windows = state.Windows
+	return status, nil
+}
+
+func (srv *server) action(w http.ResponseWriter, name, action string) {
+	s, err := loadSession(srv.conf, name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	switch action {
+	case "start":
+		err = srv.start(s, false)
+	case "apply":
+		err = srv.start(s, true)
+	case "kill":
+		err = stopSession(srv.conf, s)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if err != nil {
+		srv.metrics.failed(s.Name, action)
+		writeError(w, err)
+		return
+	}
+	srv.status(w, name)
+}
+
+// start starts a session, after killing it if rebuild is set.
+func (srv *server) start(s *config.Session, rebuild bool) error {
+	b, err := newBackend(srv.conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if rebuild && backend.Running(b) {
+		err = b.Kill()
+		if err != nil {
+			return errgo.Mask(err)
+		}
+		notifyEvent(s, config.EventSessionKilled)
+	}
+	return errgo.Mask(startBackend(b, s, s.Name, srv.metrics.timeSetup(localSetup(s))), errgo.Any)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch errgo.Cause(err) {
+	case errConfigNotFound:
+		status = http.StatusNotFound
+	case errParse:
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
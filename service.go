@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// systemdUnit is the systemd user unit that starts a session at login. It's
+// given the session's name, the tmuxg executable, the session file and
+// $PATH, escaped by systemdUnitFile.
+const systemdUnit = `[Unit]
+Description=tmuxg session %[1]s
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+Environment=%[4]s
+ExecStart=%[2]s -no-attach start %[3]s
+ExecStop=%[2]s stop %[3]s
+
+[Install]
+WantedBy=default.target
+`
+
+// systemdUnitFile fills in unit, a systemd unit, for a session.
+func systemdUnitFile(unit, name, tmuxg, path string) string {
+	return fmt.Sprintf(unit,
+		strings.Replace(name, "%", "%%", -1),
+		systemdQuote(tmuxg),
+		systemdQuote(path),
+		systemdString("PATH="+os.Getenv("PATH")))
+}
+
+// systemdString quotes s for a unit file, as systemd.syntax(7) describes:
+// in double quotes, with backslashes and quotes escaped, and % doubled so
+// that it isn't taken for a specifier.
+func systemdString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%").Replace(s) + `"`
+}
+
+// systemdQuote quotes s as a single word of a unit's command line, as
+// systemdString does, with $ doubled too so that it isn't taken for a
+// variable.
+func systemdQuote(s string) string {
+	return systemdString(strings.Replace(s, "$", "$$", -1))
+}
+
+// serviceSession returns the session to install a service for, and the
+// absolute path of its file.
+func serviceSession(conf *config.Config, arg string) (*config.Session, string, error) {
+	path, err := config.Locate(arg)
+	if err != nil {
+		return nil, "", errgo.WithCausef(err, errConfigNotFound, "")
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, "", errgo.Mask(err)
+	}
+	s, err := loadSession(conf, path)
+	if err != nil {
+		return nil, "", errgo.Mask(err, errgo.Any)
+	}
+	return s, path, nil
+}
+
+// systemdUnitPath returns the path of the systemd user unit for the named
+// session.
+func systemdUnitPath(name string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "systemd", "user", "tmuxg-"+name+".service")
+}
+
+// installServiceCommand installs and enables a service that starts a session
+// in the background at login: a systemd user unit, or a launchd user agent
+// on macOS.
+func installServiceCommand(conf *config.Config, args []string) error {
+	err := commandArgs("install-service", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, path, err := serviceSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	tmuxg, err := os.Executable()
+	if err != nil {
+		return errgo.Notef(err, "cannot find the tmuxg executable")
+	}
+	if runtime.GOOS == "darwin" {
+		return errgo.Mask(installLaunchdAgent(s.Name, tmuxg, path))
+	}
+	return errgo.Mask(installSystemdUnit(s.Name, tmuxg, path))
+}
+
+// uninstallServiceCommand disables and removes a session's service.
+func uninstallServiceCommand(conf *config.Config, args []string) error {
+	err := commandArgs("uninstall-service", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, _, err := serviceSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if runtime.GOOS == "darwin" {
+		return errgo.Mask(uninstallLaunchdAgent(s.Name))
+	}
+	return errgo.Mask(uninstallSystemdUnit(s.Name))
+}
+
+func installSystemdUnit(name, tmuxg, path string) error {
+	unitPath := systemdUnitPath(name)
+	err := os.MkdirAll(filepath.Dir(unitPath), 0755)
+	if err != nil {
+		return errgo.Notef(err, "failed to create systemd user unit directory")
+	}
+	unit := systemdUnitFile(systemdUnit, name, tmuxg, path)
+	err = ioutil.WriteFile(unitPath, []byte(unit), 0644)
+	if err != nil {
+		return errgo.Notef(err, "failed to write systemd unit %q", unitPath)
+	}
+	for _, args := range [][]string{
+		{"--user", "daemon-reload"},
+		{"--user", "enable", filepath.Base(unitPath)},
+	} {
+		err = systemctl(args...)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	fmt.Printf("installed %s\n", unitPath)
+	fmt.Println("to keep it running after you log out, run: loginctl enable-linger")
+	return nil
+}
+
+func uninstallSystemdUnit(name string) error {
+	unitPath := systemdUnitPath(name)
+	if _, err := os.Stat(unitPath); err != nil {
+		return errgo.Newf("session %q has no service installed", name)
+	}
+	err := systemctl("--user", "disable", filepath.Base(unitPath))
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	err = os.Remove(unitPath)
+	if err != nil {
+		return errgo.Notef(err, "failed to remove systemd unit %q", unitPath)
+	}
+	err = systemctl("--user", "daemon-reload")
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	fmt.Printf("removed %s\n", unitPath)
+	return nil
+}
+
+func systemctl(args ...string) error {
+	c := exec.Command("systemctl", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	err := c.Run()
+	if err != nil {
+		return errgo.Notef(err, "systemctl %s failed", args[1])
+	}
+	return nil
+}
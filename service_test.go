@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"/usr/bin/tmuxg", `"/usr/bin/tmuxg"`},
+		{"/home/me/My Sessions/dev.yaml", `"/home/me/My Sessions/dev.yaml"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\tmuxg`, `"C:\\tmuxg"`},
+		{"100%/$HOME", `"100%%/$$HOME"`},
+	}
+	for _, test := range tests {
+		if got := systemdQuote(test.word); got != test.want {
+			t.Errorf("systemdQuote(%q): got %s, want %s", test.word, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// windowScriptFunc returns a command that runs a window's setup script, and
+// a function that cleans up after it.
+type windowScriptFunc func(w *config.Window) (*exec.Cmd, func(), error)
+
+// runWindowSetupScripts runs the setup scripts of the session's windows at
+// the same time, with the commands command returns. Their output is
+// interleaved, each line prefixed with the window's name, and followed by a
+// summary of which succeeded.
+func runWindowSetupScripts(ctx context.Context, s *config.Session, command windowScriptFunc) error {
+	var windows []*config.Window
+	for i := range s.Windows {
+		if s.Windows[i].SetupScript != "" {
+			windows = append(windows, &s.Windows[i])
+		}
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make([]error, len(windows))
+	for i, w := range windows {
+		wg.Add(1)
+		go func(i int, w *config.Window) {
+			defer wg.Done()
+			errs[i] = runWindowSetupScript(ctx, w, command, &mu)
+		}(i, w)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, w := range windows {
+		if errs[i] != nil {
+			log.Printf("setup of window %q failed: %v", w.Name, errs[i])
+			failed = append(failed, w.Name)
+		} else {
+			log.Printf("setup of window %q succeeded", w.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return errgo.Newf("setup of windows %q failed", failed)
+	}
+	return nil
+}
+
+// localWindowScript returns a windowScriptFunc that runs a window's setup
+// script here, in its working directory, if that exists.
+func localWindowScript(s *config.Session) windowScriptFunc {
+	return func(w *config.Window) (*exec.Cmd, func(), error) {
+		c, cleanup, err := scriptCommand(w.SetupScript)
+		if err != nil {
+			return nil, nil, errgo.Mask(err)
+		}
+		c.Env = s.Env()
+		if cwd := backend.WindowCwd(s, w); cwd != "" && isDir(cwd) {
+			c.Dir = cwd
+		}
+		return c, cleanup, nil
+	}
+}
+
+// runWindowSetupScript runs the window's setup script with the command
+// command returns, writing its output prefixed with the window's name. mu
+// serializes writing the output.
+func runWindowSetupScript(ctx context.Context, w *config.Window, command windowScriptFunc, mu *sync.Mutex) error {
+	c, cleanup, err := command(w)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer cleanup()
+	r, pw := io.Pipe()
+	c.Stdout = pw
+	c.Stderr = pw
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			mu.Lock()
+			fmt.Fprintf(os.Stdout, "[%s] %s\n", w.Name, scanner.Text())
+			mu.Unlock()
+		}
+		io.Copy(ioutil.Discard, r)
+	}()
+	err = runScript(ctx, c, false)
+	pw.Close()
+	<-done
+	return errgo.Mask(err)
+}
+
+// setupKillGrace is how long a setup script is given to stop, once asked
+// to, before it's killed.
+const setupKillGrace = 5 * time.Second
+
+// setupContext returns a context for running the session's setup scripts,
+// which is done when the setup timeout runs out. Interrupts are passed on
+// to the scripts themselves, by runScript.
+func setupContext(s *config.Session) (context.Context, context.CancelFunc) {
+	if s.Setup.Timeout > 0 {
+		return context.WithTimeout(context.Background(), s.Setup.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// runScript runs the setup script command c in a process group of its own,
+// so that if ctx is done first, the script can be stopped along with
+// everything it started. Interrupts and SIGTERM that tmuxg gets are passed
+// on to the whole group. An interactive script reads tmuxg's stdin, and if
+// that's a terminal, has it as the foreground process group until it's
+// done, so that it can prompt, and Ctrl-C reaches it directly.
+func runScript(ctx context.Context, c *exec.Cmd, interactive bool) error {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if interactive {
+		c.Stdin = os.Stdin
+		if isTerminal(os.Stdin) {
+			c.SysProcAttr.Foreground = true
+			c.SysProcAttr.Ctty = int(os.Stdin.Fd())
+			// Taking the terminal back from the background
+			// needs SIGTTOU ignored.
+			signal.Ignore(syscall.SIGTTOU)
+			defer signal.Reset(syscall.SIGTTOU)
+			defer takeTerminal(os.Stdin)
+		}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	err := c.Start()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+
+	pgid := -c.Process.Pid
+wait:
+	for {
+		select {
+		case err := <-done:
+			return errgo.Mask(err)
+		case sig := <-signals:
+			log.Printf("passing %v on to setup", sig)
+			syscall.Kill(pgid, sig.(syscall.Signal))
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	syscall.Kill(pgid, syscall.SIGTERM)
+	select {
+	case <-done:
+	case <-time.After(setupKillGrace):
+		syscall.Kill(pgid, syscall.SIGKILL)
+		<-done
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errgo.New("setup timed out")
+	}
+	return errgo.New("setup interrupted")
+}
+
+// isTerminal returns whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// takeTerminal makes tmuxg's process group the foreground process group of
+// the terminal f again, once a setup script given it is done.
+func takeTerminal(f *os.File) {
+	pgrp := int32(syscall.Getpgrp())
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	if errno != 0 {
+		log.Printf("failed to take back the terminal: %v", errno)
+	}
+}
+
+// stateDir is where tmuxg keeps track of what it's done, such as setting up
+// sessions.
+func stateDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+	return filepath.Join(dir, "tmuxg")
+}
+
+// setupStatePath is the file recording the setup scripts a session was
+// last set up with.
+func setupStatePath(s *config.Session) string {
+	return filepath.Join(stateDir(), "setup", s.Name)
+}
+
+// setupHash is a hash of the session's setup scripts, which changes if
+// any of them do.
+func setupHash(s *config.Session) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", s.SetupScript)
+	for _, w := range s.Windows {
+		if w.SetupScript != "" {
+			fmt.Fprintf(h, "%q %q\n", w.Name, w.SetupScript)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// hasSetupScripts returns whether the session or any of its windows have
+// setup scripts.
+func hasSetupScripts(s *config.Session) bool {
+	if s.SetupScript != "" {
+		return true
+	}
+	for _, w := range s.Windows {
+		if w.SetupScript != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// readSetupState returns the hash of the setup scripts the session was last
+// set up with, and when. If it's never been set up, the error satisfies
+// os.IsNotExist.
+func readSetupState(s *config.Session) (string, time.Time, error) {
+	path := setupStatePath(s)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, errgo.Notef(err, "failed to read setup state %q", path)
+	}
+	return strings.TrimSpace(string(contents)), info.ModTime(), nil
+}
+
+// writeSetupState records that the session was just set up with its
+// current setup scripts.
+func writeSetupState(s *config.Session) error {
+	path := setupStatePath(s)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errgo.Notef(err, "failed to create state directory %q", filepath.Dir(path))
+	}
+	err = ioutil.WriteFile(path, []byte(setupHash(s)+"\n"), 0600)
+	if err != nil {
+		return errgo.Notef(err, "failed to write setup state %q", path)
+	}
+	return nil
+}
+
+// setupStatus describes whether the session has been set up, for tmuxg
+// status.
+func setupStatus(s *config.Session) (string, error) {
+	if !hasSetupScripts(s) {
+		return "no setup script", nil
+	}
+	hash, at, err := readSetupState(s)
+	if os.IsNotExist(err) {
+		return "not set up by tmuxg", nil
+	} else if err != nil {
+		return "", errgo.Mask(err)
+	}
+	when := at.Format("2006-01-02 15:04")
+	if hash != setupHash(s) {
+		return fmt.Sprintf("set up %s, setup script changed since", when), nil
+	}
+	return fmt.Sprintf("set up %s", when), nil
+}
+
+// addSetupWindow adds a window to the session, before the others, that
+// runs its setup script, for in-window setup. The other windows' commands
+// wait for it to finish, holding a tmux wait-for lock, and then run their
+// own setup scripts first.
+func addSetupWindow(s *config.Session) error {
+	dir := filepath.Join(runtimeDir(), "setup", s.Name)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return errgo.Notef(err, "failed to create setup directory %q", dir)
+	}
+	writeScript := func(name, script string) (string, error) {
+		path := filepath.Join(dir, name)
+		err := ioutil.WriteFile(path, []byte(strings.TrimSpace(script)+"\n"), 0700)
+		if err != nil {
+			return "", errgo.Notef(err, "failed to write setup script %q", path)
+		}
+		return config.ShellQuote(path), nil
+	}
+
+	lock := config.ShellQuote("tmuxg-setup-" + s.Name)
+	locked := "tmuxg-setup-" + s.Name + "-locked"
+	cwd, err := setupCwd(s)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	setup := config.Window{
+		Name:    "setup",
+		Command: "true",
+		Cwd:     cwd,
+	}
+	if s.SetupScript != "" {
+		setup.Command, err = writeScript("setup", s.SetupScript)
+		if err != nil {
+			return errgo.Mask(err)
+		}
+	}
+	if hasSetupScripts(s) {
+		// Windows' setup scripts are run by the windows themselves, so
+		// they're taken as done once they're started.
+		statePath := setupStatePath(s)
+		setup.Command = fmt.Sprintf("%s && mkdir -p %s && echo %s > %s",
+			setup.Command, config.ShellQuote(filepath.Dir(statePath)), setupHash(s), config.ShellQuote(statePath))
+	}
+	if *notifySetupFlag {
+		notify := func(message string) string {
+			return config.ShellQuote(desktopNotifyCommand("tmuxg", fmt.Sprintf(message, s.Name)).Args...) + " 2>/dev/null"
+		}
+		setup.Command = fmt.Sprintf("if %s; then %s || true; else %s; false; fi",
+			setup.Command, notify("Setup of %s finished"), notify("Setup of %s failed"))
+	}
+	setup.Command = fmt.Sprintf("tmux wait-for -L %s; tmux wait-for -S %s; "+
+		"%s || { echo 'Setup failed. The other windows start when this shell exits.'; ${SHELL:-sh}; }; "+
+		"tmux wait-for -U %s",
+		lock, config.ShellQuote(locked), setup.Command, lock)
+
+	ordered, err := config.OrderWindows(s.Windows)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	windows := []config.Window{setup}
+	for i, w := range ordered {
+		w.Gate = fmt.Sprintf("tmux wait-for -L %s && tmux wait-for -U %s", lock, lock)
+		if w.SetupScript != "" {
+			script, err := writeScript(fmt.Sprintf("window-%d", i), w.SetupScript)
+			if err != nil {
+				return errgo.Mask(err)
+			}
+			w.Gate += " && " + script
+		}
+		if i == 0 && w.WaitFor == "" {
+			// Don't create windows until the setup window holds the
+			// lock they wait for.
+			w.WaitFor = locked
+		}
+		windows = append(windows, w)
+	}
+	s.Windows = windows
+	s.Focus = setup.Name
+	return nil
+}
+
+// setupCwd returns the directory the session's setup script runs in,
+// creating it if need be.
+func setupCwd(s *config.Session) (string, error) {
+	dir := setupDir(s, isDir)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to create setup directory %q", dir)
+	}
+	return dir, nil
+}
+
+// setupDir returns the directory the session's setup script runs in, given
+// how to tell whether a directory exists where it runs.
+func setupDir(s *config.Session, isDir func(dir string) bool) string {
+	dir := s.ExpandEnv(s.Setup.Cwd)
+	switch {
+	case dir != "":
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(s.Dir, dir)
+		}
+	case s.Cwd == "":
+		dir = s.Dir
+	default:
+		dir = s.ExpandEnv(s.Cwd)
+		if !isDir(dir) {
+			// The setup script usually creates the session's
+			// directory, so it runs next to it.
+			dir = filepath.Dir(dir)
+		}
+	}
+	return dir
+}
+
+// isDir returns whether dir is a directory.
+func isDir(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
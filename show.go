@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// showCommand prints a session as tmuxg will build it.
+func showCommand(conf *config.Config, args []string) error {
+	err := commandArgs("show", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	resolved, err := resolveSession(s)
+	if err != nil {
+		return errgo.WithCausef(err, errParse, "")
+	}
+	out, err := yaml.Marshal(resolved)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	_, err = os.Stdout.Write(out)
+	return errgo.Mask(err)
+}
+
+// resolveSession returns the session as tmuxg will build it: its settings
+// merged with config.yaml's, environment variables expanded, windows in the
+// order they're created, and each window's working directory and command as
+// run, in its container if it has one. Windows repeated for worktrees or
+// pods aren't, as finding those has side effects.
+func resolveSession(s *config.Session) (*config.Session, error) {
+	resolved := *s
+	resolved.Cwd = s.ExpandEnv(s.Cwd)
+	resolved.SetupScript = s.ExpandEnv(s.SetupScript)
+	resolved.Environment = make(map[string]string)
+	for k := range s.Environment {
+		resolved.Environment[k] = s.Getenv(k)
+	}
+	windows, err := config.OrderWindows(s.Windows)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	for i := range windows {
+		w := &windows[i]
+		panes := make([]config.Pane, len(w.Panes))
+		for j := range w.Panes {
+			pw := backend.PaneWindow(w, &w.Panes[j])
+			panes[j] = w.Panes[j]
+			panes[j].Cwd = backend.WindowCwd(s, pw)
+			panes[j].Command = backend.WindowCommand(s, pw)
+		}
+		w.Cwd = backend.WindowCwd(s, w)
+		w.Command = backend.WindowCommand(s, w)
+		w.Panes = panes
+		w.Container = nil
+		w.VagrantSSH = ""
+	}
+	resolved.Windows = windows
+	resolved.Container = nil
+	resolved.Vagrant = false
+	return &resolved, nil
+}
+
+// envCommand prints the environment variables a session sets, as its
+// commands see them, for debugging or for eval in a shell.
+func envCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("env", flag.ContinueOnError)
+	format := fs.String("format", "shell", "output format: shell or json")
+	args, err := parseCommandFlags("env", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("env", args, 1)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	s, err := loadSession(conf, args[0])
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	env := make(map[string]string)
+	for k := range s.Environment {
+		env[k] = s.Getenv(k)
+	}
+
+	switch *format {
+	case "shell":
+		var keys []string
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("export %s=%s\n", k, config.ShellQuote(env[k]))
+		}
+		return nil
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return errgo.Mask(enc.Encode(env))
+	}
+	return errgo.WithCausef(nil, errUsage, "unknown format %q, expected shell or json", *format)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// templateVars are variables given to templates with -set name=value.
+type templateVars map[string]string
+
+func (v templateVars) String() string {
+	var vars []string
+	for name, value := range v {
+		vars = append(vars, name+"="+value)
+	}
+	sort.Strings(vars)
+	return strings.Join(vars, ",")
+}
+
+func (v templateVars) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return errgo.Newf("%q is not name=value", s)
+	}
+	v[s[:i]] = s[i+1:]
+	return nil
+}
+
+var setFlag = templateVars{}
+
+func init() {
+	flag.Var(setFlag, "set", "set a template variable, as name=value (may be repeated)")
+}
+
+// builtinTemplates are the session file templates shipped with tmuxg.
+//
+//go:embed templates/*.yaml
+var builtinTemplates embed.FS
+
+// templateDir is where users may keep their own session file templates,
+// which take precedence over the built in ones of the same name.
+func templateDir() string {
+	return filepath.Join(config.Dir(), "templates")
+}
+
+// loadTemplate returns the named session file template, from the user's
+// template directory if it's there, or else built in. Remote templates are
+// fetched.
+func loadTemplate(name string) (*template.Template, error) {
+	path := filepath.Join(templateDir(), name+".yaml")
+	if isRemoteTemplate(name) {
+		var err error
+		path, err = fetchTemplate(name)
+		if err != nil {
+			return nil, errgo.Mask(err)
+		}
+	}
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !isRemoteTemplate(name) {
+		path = "templates/" + name + ".yaml"
+		contents, err = builtinTemplates.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil, errgo.Newf("no template named %q", name)
+		}
+	}
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read template %q", path)
+	}
+	tmpl, err := template.New(name).Parse(string(contents))
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to parse template %q", path)
+	}
+	return tmpl, nil
+}
+
+// templateInfo describes an available template.
+type templateInfo struct {
+	name, description string
+
+	// user is whether the template is the user's own, rather than built
+	// in.
+	user bool
+}
+
+// listTemplates returns the available templates, sorted by name.
+func listTemplates() ([]templateInfo, error) {
+	user := map[string]bool{}
+	var names []string
+	entries, err := ioutil.ReadDir(templateDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errgo.Notef(err, "failed to read template directory")
+	}
+	for _, entry := range entries {
+		if name := strings.TrimSuffix(entry.Name(), ".yaml"); name != entry.Name() && !entry.IsDir() {
+			user[name] = true
+			names = append(names, name)
+		}
+	}
+	builtins, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	for _, entry := range builtins {
+		if name := strings.TrimSuffix(entry.Name(), ".yaml"); !user[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var templates []templateInfo
+	for _, name := range names {
+		description, err := templateDescription(name)
+		if err != nil {
+			return nil, errgo.Mask(err)
+		}
+		templates = append(templates, templateInfo{
+			name:        name,
+			description: description,
+			user:        user[name],
+		})
+	}
+	return templates, nil
+}
+
+// templateDescription returns the description of the session a template
+// generates.
+func templateDescription(name string) (string, error) {
+	tmpl, err := loadTemplate(name)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, templateData{Name: name, User: "user", Project: name})
+	if err != nil {
+		return "", errgo.Notef(err, "failed to render template %q", name)
+	}
+	var s config.Session
+	err = yaml.Unmarshal(buf.Bytes(), &s)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to parse template %q", name)
+	}
+	return s.Description, nil
+}
+
+// templateData is what a template is given to render a session file.
+type templateData struct {
+	Name, User, Project string
+
+	// Forge is the host of the project's repository, such as github.com.
+	Forge string
+
+	// Remote is the git URL to clone the project from.
+	Remote string
+
+	// ImportPath is where the project lives under a GOPATH, such as
+	// github.com/user/project.
+	ImportPath string
+
+	// Dir is the root of the project the session is being created in, if
+	// any.
+	Dir string
+
+	// Vars are variables set with -set.
+	Vars map[string]string
+
+	// Git describes the git repository the session is being created in,
+	// if any.
+	Git gitInfo
+}
+
+// gitInfo describes a git repository.
+type gitInfo struct {
+	// Branch is the branch checked out.
+	Branch string
+
+	// RemoteURL is the URL of the origin remote.
+	RemoteURL string
+
+	// Root is the root of the worktree.
+	Root string
+}
+
+// newGitInfo describes the git repository containing dir. Outside a
+// repository, it's empty.
+func newGitInfo(dir string) gitInfo {
+	query := func(args ...string) string {
+		c := exec.Command("git", args...)
+		c.Dir = dir
+		out, err := c.Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+	return gitInfo{
+		Branch:    query("rev-parse", "--abbrev-ref", "HEAD"),
+		RemoteURL: query("config", "--get", "remote.origin.url"),
+		Root:      query("rev-parse", "--show-toplevel"),
+	}
+}
+
+// newTemplateData returns what a template is given to render the named
+// session, from the command line flags.
+func newTemplateData(name, dir string) templateData {
+	d := templateData{
+		Name:    name,
+		User:    *userFlag,
+		Project: *projectFlag,
+		Forge:   *forgeFlag,
+		Remote:  *remoteFlag,
+		Dir:     dir,
+		Vars:    setFlag,
+	}
+	if wd, err := os.Getwd(); err == nil {
+		d.Git = newGitInfo(wd)
+	}
+	if d.User == "" {
+		d.User = os.Getenv("USER")
+	}
+	if d.Project == "" {
+		d.Project = name
+	}
+	if d.Remote == "" {
+		if *sshFlag {
+			d.Remote = fmt.Sprintf("git@%s:%s/%s.git", d.Forge, d.User, d.Project)
+		} else {
+			d.Remote = fmt.Sprintf("https://%s/%s/%s", d.Forge, d.User, d.Project)
+		}
+		d.ImportPath = path.Join(d.Forge, d.User, d.Project)
+	} else {
+		d.ImportPath = remoteImportPath(d.Remote)
+	}
+	return d
+}
+
+// remoteImportPath returns the GOPATH location for a project cloned from a
+// git URL, which may be an scp-like SSH address: https://host/a/b,
+// ssh://git@host/a/b.git and git@host:a/b.git are all host/a/b.
+func remoteImportPath(remote string) string {
+	p := remote
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+3:]
+	} else {
+		p = strings.Replace(p, ":", "/", 1)
+	}
+	if i := strings.Index(p, "@"); i >= 0 {
+		p = p[i+1:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(p, "/"), ".git")
+}
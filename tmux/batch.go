@@ -0,0 +1,35 @@
+package tmux
+
+import (
+	"strings"
+)
+
+// batch is a sequence of tmux commands to run in a single tmux invocation,
+// saving a fork and a round trip to the server for each. tmux runs the
+// commands in order, and stops at the first one that fails.
+type batch struct {
+	args []string
+}
+
+// add appends a command to the batch.
+func (b *batch) add(args ...string) {
+	if len(b.args) > 0 {
+		b.args = append(b.args, ";")
+	}
+	for _, arg := range args {
+		// tmux takes an argument ending in a semicolon to end the
+		// command, unless the semicolon is escaped.
+		if strings.HasSuffix(arg, ";") {
+			arg = arg[:len(arg)-1] + `\;`
+		}
+		b.args = append(b.args, arg)
+	}
+}
+
+// runBatch runs the batched commands, if there are any.
+func (s *Session) runBatch(b *batch) error {
+	if len(b.args) == 0 {
+		return nil
+	}
+	return s.Run(b.args...)
+}
@@ -0,0 +1,127 @@
+package tmux
+
+import (
+	"log"
+	"os"
+	"os/exec"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// Runner runs tmux commands. The tmuxtest package has a fake Runner for
+// testing code that drives sessions without a live tmux.
+type Runner interface {
+	// Run runs tmux with args in the directory dir, connected to the
+	// terminal.
+	Run(dir string, args []string) error
+
+	// Output runs tmux with args in the directory dir, and returns its
+	// standard output.
+	Output(dir string, args []string) ([]byte, error)
+}
+
+// ExecRunner runs commands with a tmux executable.
+type ExecRunner struct {
+	// Bin is the tmux executable to run.
+	Bin string
+
+	// Env, if set, is the environment tmux runs with, which a server it
+	// starts inherits.
+	Env []string
+}
+
+func (r *ExecRunner) command(dir string, args []string) *exec.Cmd {
+	c := exec.Command(r.Bin, args...)
+	c.Dir = dir
+	c.Env = r.Env
+	return c
+}
+
+// Run implements Runner.
+func (r *ExecRunner) Run(dir string, args []string) error {
+	c := r.command(dir, args)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	return errgo.Mask(c.Run())
+}
+
+// Output implements Runner.
+func (r *ExecRunner) Output(dir string, args []string) ([]byte, error) {
+	out, err := r.command(dir, args).Output()
+	return out, errgo.Mask(err)
+}
+
+// SSHRunner runs commands with tmux on another host, over ssh.
+type SSHRunner struct {
+	// Host is the host to run tmux on, as given to ssh.
+	Host string
+
+	// Bin is the tmux executable to run on the host.
+	Bin string
+
+	// ControlPath, if set, is the control socket of an ssh master
+	// connection to the host, which commands share rather than each
+	// connecting afresh.
+	ControlPath string
+}
+
+func (r *SSHRunner) command(tty bool, dir string, args []string) *exec.Cmd {
+	var sshArgs []string
+	if r.ControlPath != "" {
+		sshArgs = append(sshArgs, "-S", r.ControlPath)
+	}
+	if tty {
+		sshArgs = append(sshArgs, "-q", "-t")
+	}
+	script := "exec " + config.ShellQuote(r.Bin)
+	for _, arg := range args {
+		script += " " + config.ShellQuote(arg)
+	}
+	if dir != "" {
+		script = "cd " + config.ShellQuote(dir) + " 2>/dev/null; " + script
+	}
+	return exec.Command("ssh", append(sshArgs, r.Host, script)...)
+}
+
+// Run implements Runner.
+func (r *SSHRunner) Run(dir string, args []string) error {
+	c := r.command(true, dir, args)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	return errgo.Mask(c.Run())
+}
+
+// Output implements Runner.
+func (r *SSHRunner) Output(dir string, args []string) ([]byte, error) {
+	out, err := r.command(false, dir, args).Output()
+	return out, errgo.Mask(err)
+}
+
+// MoshRunner runs commands with tmux on another host, over mosh for those
+// connected to the terminal, so that they survive a flaky network, and
+// over ssh for the rest.
+type MoshRunner struct {
+	*SSHRunner
+}
+
+// Run implements Runner. mosh runs tmux in the remote user's home
+// directory, rather than dir.
+func (r *MoshRunner) Run(dir string, args []string) error {
+	var moshArgs []string
+	if r.ControlPath != "" {
+		moshArgs = append(moshArgs, "--ssh=ssh -S "+r.ControlPath)
+	}
+	moshArgs = append(moshArgs, r.Host, "--", r.Bin)
+	c := exec.Command("mosh", append(moshArgs, args...)...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	return errgo.Mask(c.Run())
+}
@@ -0,0 +1,403 @@
+// Package tmux builds and drives tmux sessions described by tmuxg session
+// models.
+package tmux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// ErrNested is the cause of an Attach refused because it would nest tmux
+// clients.
+var ErrNested = errgo.New("refusing to nest tmux")
+
+// Session is a tmux session built from a session model.
+type Session struct {
+	*config.Session
+
+	// Runner runs the session's tmux commands.
+	Runner Runner
+
+	// AttachRunner, if set, runs the command that attaches to the
+	// session instead of Runner.
+	AttachRunner Runner
+
+	// pending holds commands deferred until Flush.
+	pending batch
+
+	// lastIndex is the index of the last window created, or the highest
+	// index of one pinned before it.
+	lastIndex int
+}
+
+var (
+	_ backend.Backend      = (*Session)(nil)
+	_ backend.OutputLogger = (*Session)(nil)
+	_ backend.Waiter       = (*Session)(nil)
+)
+
+// New returns a Session for the given model, run with the tmux executable
+// bin.
+func New(s *config.Session, bin string) *Session {
+	return &Session{Session: s, Runner: &ExecRunner{Bin: bin, Env: s.Env()}}
+}
+
+// args returns the tmux arguments for a command addressing the session's
+// server.
+func (s *Session) args(args []string) []string {
+	var globalArgs []string
+	if s.TmuxConfig != "" {
+		// Relative to the session file, so a project can ship its own.
+		conf := s.ExpandEnv(s.TmuxConfig)
+		if !filepath.IsAbs(conf) {
+			conf = filepath.Join(s.Dir, conf)
+		}
+		globalArgs = append(globalArgs, "-f", conf)
+	}
+	globalArgs = append(globalArgs, s.serverArgs()...)
+	return append(globalArgs, args...)
+}
+
+// Run runs a tmux command on the session's server, connected to the
+// terminal.
+func (s *Session) Run(args ...string) error {
+	return errgo.Mask(s.Runner.Run(s.ExpandEnv(s.Cwd), s.args(args)))
+}
+
+// Output runs a tmux command on the session's server and returns its
+// standard output.
+func (s *Session) Output(args ...string) ([]byte, error) {
+	out, err := s.Runner.Output(s.ExpandEnv(s.Cwd), s.args(args))
+	return out, errgo.Mask(err)
+}
+
+// Attach connects the terminal to the session. When run from a tmux client
+// on the same server, that client is switched to the session instead of
+// nesting a new client inside it. Nesting a client from another server is
+// refused unless allowed, as the outer tmux swallows the inner one's key
+// bindings.
+func (s *Session) Attach(opts backend.AttachOptions) error {
+	if current := currentSocket(); current != "" {
+		// A session on another host is never on the server we're in.
+		if _, remote := s.Runner.(*SSHRunner); !remote {
+			socket, err := s.QuerySocketPath()
+			if err != nil {
+				return errgo.Mask(err)
+			}
+			if socket == current {
+				err = s.Run("switch-client", "-t", s.Name)
+				if err != nil || len(s.AfterAttach) == 0 {
+					return errgo.Mask(err)
+				}
+				// Switching doesn't attach, so nothing hooks it.
+				return errgo.Mask(s.Run("if-shell", "-F", "1", s.afterAttach()))
+			}
+		}
+		if !opts.AllowNested {
+			return errgo.WithCausef(nil, ErrNested,
+				"refusing to attach to session %q from inside another tmux server (%s); "+
+					"the session is running, so detach from this tmux and run tmuxg again "+
+					"from a plain terminal, or use -allow-nested to attach anyway",
+				s.Name, current)
+		}
+	}
+	if len(s.AfterAttach) > 0 {
+		err := s.Run("set-hook", "-t", s.Name, "client-attached", s.afterAttach())
+		if err != nil {
+			return errgo.Notef(err, "failed to hook after-attach commands")
+		}
+	}
+	args := []string{"attach", "-t", s.Name}
+	if opts.ReadOnly {
+		args = append(args, "-r")
+	}
+	if opts.DetachOthers {
+		args = append(args, "-d")
+	}
+	runner := s.Runner
+	if s.AttachRunner != nil {
+		runner = s.AttachRunner
+	}
+	if r, ok := runner.(*ExecRunner); ok && currentSocket() != "" {
+		// tmux itself refuses to nest while $TMUX is set.
+		runner = &ExecRunner{Bin: r.Bin, Env: withoutEnv(r.Env, "TMUX")}
+	}
+	return errgo.Mask(runner.Run(s.ExpandEnv(s.Cwd), s.args(args)))
+}
+
+// withoutEnv returns env, or the process's environment if env is nil,
+// without the variable key.
+func withoutEnv(env []string, key string) []string {
+	if env == nil {
+		env = os.Environ()
+	}
+	result := make([]string, 0, len(env))
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+// afterAttach returns the session's after-attach commands as a tmux
+// command list.
+func (s *Session) afterAttach() string {
+	return strings.Join(s.AfterAttach, " ; ")
+}
+
+// currentSocket returns the socket of the tmux server that we were run
+// inside of, or "" if not run inside tmux.
+func currentSocket() string {
+	env := os.Getenv("TMUX")
+	if env == "" {
+		return ""
+	}
+	// $TMUX is "socket_path,server_pid,session_index".
+	return strings.Split(env, ",")[0]
+}
+
+// QuerySocketPath asks the session's running server for the path of the
+// socket it listens on.
+func (s *Session) QuerySocketPath() (string, error) {
+	out, err := s.Output("display-message", "-p", "-t", s.Name, "#{socket_path}")
+	if err != nil {
+		return "", errgo.Notef(err, "failed to query socket of session %q", s.Name)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Exists returns whether the session is already running.
+func (s *Session) Exists() bool {
+	_, err := s.Output("has-session", "-t", s.Name)
+	return err == nil
+}
+
+// Kill kills the session.
+func (s *Session) Kill() error {
+	err := s.Run("kill-session", "-t", s.Name)
+	if err != nil {
+		return errgo.Notef(err, "failed to kill session %q", s.Name)
+	}
+	return nil
+}
+
+// KillServer kills the session's tmux server, and so every session on it.
+func (s *Session) KillServer() error {
+	err := s.Run("kill-server")
+	if err != nil {
+		return errgo.Notef(err, "failed to kill tmux server for session %q", s.Name)
+	}
+	return nil
+}
+
+// CreateSession implements backend.Backend. The session is created
+// straight away, and its environment set along with its other windows when
+// flushed.
+func (s *Session) CreateSession(w *config.Window) (string, error) {
+	if w.LinkWindow != "" {
+		return "", errgo.Newf("the first window, %q, can't be linked from another session", w.Name)
+	}
+	err := s.Run("new-session", "-d", "-s", s.Name, "-n", w.Name,
+		"-c", backend.WindowCwd(s.Session, w), backend.WindowCommand(s.Session, w))
+	if err != nil {
+		return "", errgo.Notef(err, "failed to start tmux session")
+	}
+	// Windows are numbered from base-index, which tmux.conf may have
+	// changed from 0.
+	out, err := s.Output("display-message", "-p", "-t", s.Name, "#{window_index}")
+	if err != nil {
+		return "", errgo.Notef(err, "failed to query first window of session %q", s.Name)
+	}
+	s.lastIndex, err = strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return "", errgo.Notef(err, "unexpected window index %q", out)
+	}
+	if w.Index != nil && *w.Index != s.lastIndex {
+		err = s.Run("move-window", "-s", fmt.Sprintf("%s:%d", s.Name, s.lastIndex),
+			"-t", fmt.Sprintf("%s:%d", s.Name, *w.Index))
+		if err != nil {
+			return "", errgo.Notef(err, "failed to move window %q to index %d", w.Name, *w.Index)
+		}
+		s.lastIndex = *w.Index
+	}
+	target := fmt.Sprintf("%s:%d", s.Name, s.lastIndex)
+	for k := range s.Environment {
+		s.pending.add("set-environment", "-t", s.Name, k, s.Getenv(k))
+	}
+	if s.RenumberWindows {
+		s.pending.add("set-option", "-t", s.Name, "renumber-windows", "on")
+	}
+	return target, nil
+}
+
+// pinned returns whether a window of the session is pinned to index.
+func (s *Session) pinned(index int) bool {
+	for _, w := range s.Windows {
+		if w.Index != nil && *w.Index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWindow implements backend.Backend. Windows are created in order,
+// each at the index after the last, skipping indices other windows are
+// pinned to, or else at the index they're pinned to. The rest of the
+// session is set up in one go when flushed, as forking tmux for every
+// window is slow with many windows.
+func (s *Session) CreateWindow(i int, w *config.Window) (string, error) {
+	if i < 0 && w.Index != nil {
+		target := fmt.Sprintf("%s:%d", s.Name, *w.Index)
+		s.pending.add(s.windowArgs(target, w)...)
+		return target, nil
+	}
+	if i < 0 {
+		// Where it lands isn't known until it's created.
+		err := s.Flush()
+		if err != nil {
+			return "", errgo.Mask(err)
+		}
+		out, err := s.Output("new-window", "-d", "-P", "-F", "#{window_id}", "-t", s.Name+":",
+			"-n", w.Name, "-c", backend.WindowCwd(s.Session, w), backend.WindowCommand(s.Session, w))
+		if err != nil {
+			return "", errgo.Notef(err, "failed to create window %q", w.Name)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	index := s.lastIndex + 1
+	for s.pinned(index) {
+		index++
+	}
+	if w.Index != nil {
+		index = *w.Index
+	}
+	if index > s.lastIndex {
+		s.lastIndex = index
+	}
+	target := fmt.Sprintf("%s:%d", s.Name, index)
+	s.pending.add(s.windowArgs(target, w)...)
+	return target, nil
+}
+
+// windowArgs returns the tmux command that creates the window w at target,
+// or links it there from another session.
+func (s *Session) windowArgs(target string, w *config.Window) []string {
+	if w.LinkWindow != "" {
+		return []string{"link-window", "-d", "-s", w.LinkWindow, "-t", target}
+	}
+	return []string{"new-window", "-d", "-t", target, "-n", w.Name,
+		"-c", backend.WindowCwd(s.Session, w), backend.WindowCommand(s.Session, w)}
+}
+
+// SplitPane implements backend.Backend. The pane is created straight away,
+// so that its ID can be returned.
+func (s *Session) SplitPane(target, cwd, command string) (string, error) {
+	err := s.Flush()
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	out, err := s.Output("split-window", "-d", "-P", "-F", "#{pane_id}",
+		"-t", target, "-c", cwd, command)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to split %q", target)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SendKeys implements backend.Backend. Each keystroke is a tmux key name,
+// or a friendly one backend.KeyName knows, or else text to type.
+func (s *Session) SendKeys(target string, keystrokes []string) error {
+	args := []string{"send-keys", "-t", target}
+	for _, k := range keystrokes {
+		args = append(args, backend.KeyName(k))
+	}
+	s.pending.add(args...)
+	return nil
+}
+
+// SendText implements backend.Backend, with send-keys -l.
+func (s *Session) SendText(target, text string) error {
+	// Text that looks like a flag is still text.
+	s.pending.add("send-keys", "-t", target, "-l", "--", text)
+	return nil
+}
+
+// Paste implements backend.Backend, through a paste buffer of its own that's
+// deleted once pasted.
+func (s *Session) Paste(target, text string) error {
+	buffer := "tmuxg-" + target
+	s.pending.add("set-buffer", "-b", buffer, "--", text)
+	s.pending.add("paste-buffer", "-d", "-p", "-b", buffer, "-t", target)
+	return nil
+}
+
+// WaitFor implements backend.Waiter, with tmux wait-for. Once timeout has
+// passed, the channel is signalled by tmuxg itself, so that wait-for
+// returns.
+func (s *Session) WaitFor(channel string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Output("wait-for", channel)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return errgo.Mask(err)
+	case <-time.After(timeout):
+	}
+	_, err := s.Output("wait-for", "-S", channel)
+	if err == nil {
+		<-done
+	}
+	return errgo.WithCausef(nil, backend.ErrWaitTimeout, "%q wasn't signalled within %v", channel, timeout)
+}
+
+// LogOutput implements backend.OutputLogger, with pipe-pane.
+func (s *Session) LogOutput(target, path string) error {
+	s.pending.add("pipe-pane", "-o", "-t", target,
+		"mkdir -p "+config.ShellQuote(filepath.Dir(path))+" && cat >> "+config.ShellQuote(path))
+	return nil
+}
+
+// Focus implements backend.Backend. A pane, as SplitPane returns it, is
+// selected in its window, as well as its window.
+func (s *Session) Focus(target string) error {
+	s.pending.add("select-window", "-t", target)
+	if strings.HasPrefix(target, "%") {
+		s.pending.add("select-pane", "-t", target)
+	}
+	return nil
+}
+
+// Flush implements backend.Backend, running the deferred commands as a
+// batch.
+func (s *Session) Flush() error {
+	b := s.pending
+	s.pending = batch{}
+	return errgo.Mask(s.runBatch(&b))
+}
+
+// Query implements backend.Backend.
+func (s *Session) Query() (*backend.State, error) {
+	if !s.Exists() {
+		return &backend.State{}, nil
+	}
+	out, err := s.Output("list-windows", "-t", s.Name, "-F", "#{window_name}")
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to list windows of session %q", s.Name)
+	}
+	return &backend.State{
+		Running: true,
+		Windows: strings.Split(strings.TrimSpace(string(out)), "\n"),
+	}, nil
+}
@@ -0,0 +1,235 @@
+package tmux_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+	"github.com/cmars/tmuxg/tmux/tmuxtest"
+)
+
+func intp(i int) *int { return &i }
+
+func TestBuildCommands(t *testing.T) {
+	tests := []struct {
+		about     string
+		baseIndex string
+		windows   []config.Window
+		targets   []string
+		commands  []string
+	}{{
+		about: "windows are batched after the session is created",
+		windows: []config.Window{
+			{Name: "shell", Command: "bash"},
+			{Name: "editor", Command: "vim", Cwd: "/src"},
+		},
+		targets: []string{"s:0", "s:1"},
+		commands: []string{
+			"-L s new-session -d -s s -n shell -c /home bash",
+			"-L s display-message -p -t s #{window_index}",
+			"-L s set-environment -t s EDITOR vim ; new-window -d -t s:1 -n editor -c /src vim",
+		},
+	}, {
+		about:     "windows are numbered from base-index",
+		baseIndex: "1",
+		windows: []config.Window{
+			{Name: "shell", Command: "bash"},
+			{Name: "editor", Command: "vim"},
+		},
+		targets: []string{"s:1", "s:2"},
+		commands: []string{
+			"-L s new-session -d -s s -n shell -c /home bash",
+			"-L s display-message -p -t s #{window_index}",
+			"-L s set-environment -t s EDITOR vim ; new-window -d -t s:2 -n editor -c /home vim",
+		},
+	}, {
+		about: "windows skip indices others are pinned to",
+		windows: []config.Window{
+			{Name: "shell", Command: "bash"},
+			{Name: "logs", Command: "tail", Index: intp(1)},
+			{Name: "editor", Command: "vim"},
+		},
+		targets: []string{"s:0", "s:1", "s:2"},
+		commands: []string{
+			"-L s new-session -d -s s -n shell -c /home bash",
+			"-L s display-message -p -t s #{window_index}",
+			"-L s set-environment -t s EDITOR vim ; new-window -d -t s:1 -n logs -c /home tail ; new-window -d -t s:2 -n editor -c /home vim",
+		},
+	}, {
+		about: "a pinned first window is moved",
+		windows: []config.Window{
+			{Name: "shell", Command: "bash", Index: intp(3)},
+			{Name: "editor", Command: "vim"},
+		},
+		targets: []string{"s:3", "s:4"},
+		commands: []string{
+			"-L s new-session -d -s s -n shell -c /home bash",
+			"-L s display-message -p -t s #{window_index}",
+			"-L s move-window -s s:0 -t s:3",
+			"-L s set-environment -t s EDITOR vim ; new-window -d -t s:4 -n editor -c /home vim",
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.about, func(t *testing.T) {
+			baseIndex := test.baseIndex
+			if baseIndex == "" {
+				baseIndex = "0"
+			}
+			r := &tmuxtest.Runner{Outputs: map[string]string{
+				"-L s display-message -p -t s #{window_index}": baseIndex + "\n",
+			}}
+			s := &tmux.Session{
+				Session: &config.Session{
+					Name:        "s",
+					Cwd:         "/home",
+					Environment: map[string]string{"EDITOR": "vim"},
+					Windows:     test.windows,
+				},
+				Runner: r,
+			}
+			var targets []string
+			target, err := s.CreateSession(&s.Windows[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			targets = append(targets, target)
+			for i := 1; i < len(s.Windows); i++ {
+				target, err := s.CreateWindow(i, &s.Windows[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				targets = append(targets, target)
+			}
+			err = s.Flush()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(targets, test.targets) {
+				t.Errorf("targets: got %q, want %q", targets, test.targets)
+			}
+			if got := joined(r.Commands); !reflect.DeepEqual(got, test.commands) {
+				t.Errorf("commands: got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.commands, "\n"))
+			}
+		})
+	}
+}
+
+func TestBatchEscapesTrailingSemicolons(t *testing.T) {
+	r := &tmuxtest.Runner{}
+	s := &tmux.Session{Session: &config.Session{Name: "s"}, Runner: r}
+	err := s.SendKeys("s:0", []string{"make;", "Return"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SendText("s:0", "echo done;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{
+		"-L", "s",
+		"send-keys", "-t", "s:0", `make\;`, "Enter", ";",
+		"send-keys", "-t", "s:0", "-l", "--", `echo done\;`,
+	}}
+	if !reflect.DeepEqual(r.Commands, want) {
+		t.Errorf("got %q, want %q", r.Commands, want)
+	}
+}
+
+func TestTextIsNotTakenForFlags(t *testing.T) {
+	r := &tmuxtest.Runner{}
+	s := &tmux.Session{Session: &config.Session{Name: "s"}, Runner: r}
+	err := s.SendText("s:0", "-la")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Paste("s:0", "-x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{
+		"-L", "s",
+		"send-keys", "-t", "s:0", "-l", "--", "-la", ";",
+		"set-buffer", "-b", "tmuxg-s:0", "--", "-x", ";",
+		"paste-buffer", "-d", "-p", "-b", "tmuxg-s:0", "-t", "s:0",
+	}}
+	if !reflect.DeepEqual(r.Commands, want) {
+		t.Errorf("got %q, want %q", r.Commands, want)
+	}
+}
+
+func TestFlushWithoutCommands(t *testing.T) {
+	r := &tmuxtest.Runner{}
+	s := &tmux.Session{Session: &config.Session{Name: "s"}, Runner: r}
+	err := s.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Commands) != 0 {
+		t.Errorf("ran %q", r.Commands)
+	}
+}
+
+func joined(commands [][]string) []string {
+	var lines []string
+	for _, c := range commands {
+		lines = append(lines, strings.Join(c, " "))
+	}
+	return lines
+}
+
+// waitRunner is a tmux.Runner whose wait-for blocks until its channel is
+// signalled, as tmux's does.
+type waitRunner struct {
+	tmuxtest.Runner
+	signalled chan struct{}
+}
+
+func (r *waitRunner) Output(dir string, args []string) ([]byte, error) {
+	switch strings.Join(args[2:], " ") {
+	case "wait-for ch":
+		<-r.signalled
+		return nil, nil
+	case "wait-for -S ch":
+		close(r.signalled)
+		return nil, nil
+	}
+	return r.Runner.Output(dir, args)
+}
+
+func TestWaitForTimesOut(t *testing.T) {
+	r := &waitRunner{signalled: make(chan struct{})}
+	s := &tmux.Session{Session: &config.Session{Name: "s"}, Runner: r}
+	err := s.WaitFor("ch", 10*time.Millisecond)
+	if errgo.Cause(err) != backend.ErrWaitTimeout {
+		t.Fatalf("got error %v, want a timeout", err)
+	}
+	select {
+	case <-r.signalled:
+	default:
+		t.Errorf("the wait wasn't ended")
+	}
+}
+
+func TestWaitForSignalled(t *testing.T) {
+	r := &waitRunner{signalled: make(chan struct{})}
+	close(r.signalled)
+	s := &tmux.Session{Session: &config.Session{Name: "s"}, Runner: r}
+	err := s.WaitFor("ch", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,50 @@
+package tmux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// serverArgs returns the tmux arguments that select the server the session
+// runs on.
+func (s *Session) serverArgs() []string {
+	switch {
+	case s.Socket.Path != "":
+		return []string{"-S", s.ExpandEnv(s.Socket.Path)}
+	case s.Socket.Name != "":
+		return []string{"-L", s.Socket.Name}
+	case s.Socket.Strategy == config.SocketDefaultServer:
+		return nil
+	default:
+		return []string{"-L", s.Name}
+	}
+}
+
+// OwnsServer returns whether the session has a tmux server to itself.
+func (s *Session) OwnsServer() bool {
+	return s.Socket == config.Socket{} || s.Socket == config.Socket{Strategy: config.SocketPerSession}
+}
+
+// SocketPath returns the path of the socket the session's server listens
+// on, whether or not it is running, following the same rules as tmux itself:
+// sockets live in $TMUX_TMPDIR (or /tmp) under tmux-<uid>.
+func (s *Session) SocketPath() string {
+	if s.Socket.Path != "" {
+		return s.ExpandEnv(s.Socket.Path)
+	}
+	name := s.Name
+	switch {
+	case s.Socket.Name != "":
+		name = s.Socket.Name
+	case s.Socket.Strategy == config.SocketDefaultServer:
+		name = "default"
+	}
+	dir := os.Getenv("TMUX_TMPDIR")
+	if dir == "" {
+		dir = "/tmp"
+	}
+	return filepath.Join(dir, fmt.Sprintf("tmux-%d", os.Getuid()), name)
+}
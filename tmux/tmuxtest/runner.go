@@ -0,0 +1,54 @@
+// Package tmuxtest provides a fake tmux for testing code that drives tmux
+// sessions.
+package tmuxtest
+
+import (
+	"strings"
+
+	"github.com/cmars/tmuxg/tmux"
+)
+
+var _ tmux.Runner = (*Runner)(nil)
+
+// Runner is a tmux.Runner that records the commands it is given instead of
+// running them.
+type Runner struct {
+	// Commands holds the arguments of every command run, in order.
+	Commands [][]string
+
+	// Outputs holds the standard output of commands, keyed by their
+	// arguments joined with spaces. Commands not listed output nothing.
+	Outputs map[string]string
+
+	// Errors holds errors that commands fail with, keyed like Outputs.
+	// Commands not listed succeed.
+	Errors map[string]error
+}
+
+// Run implements tmux.Runner.
+func (r *Runner) Run(dir string, args []string) error {
+	_, err := r.Output(dir, args)
+	return err
+}
+
+// Output implements tmux.Runner.
+func (r *Runner) Output(dir string, args []string) ([]byte, error) {
+	r.Commands = append(r.Commands, append([]string(nil), args...))
+	key := strings.Join(args, " ")
+	if err, ok := r.Errors[key]; ok {
+		return nil, err
+	}
+	return []byte(r.Outputs[key]), nil
+}
+
+// Ran returns whether any command was run whose arguments contain the given
+// arguments, in order and adjacent.
+func (r *Runner) Ran(args ...string) bool {
+	want := strings.Join(args, "\x00")
+	for _, c := range r.Commands {
+		if strings.Contains("\x00"+strings.Join(c, "\x00")+"\x00", "\x00"+want+"\x00") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package tmux
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+)
+
+// Causes of CheckRequirements failures.
+var (
+	ErrVersion     = errgo.New("unsupported tmux version")
+	ErrRequirement = errgo.New("invalid tmux requirement")
+)
+
+// Version is a tmux release, such as 3.2a. The letter suffix of a bug
+// fix release counts as a further component, so 3.2a is 3.2.1.
+type Version []int
+
+var versionPattern = regexp.MustCompile(`(\d+(?:\.\d+)*)([a-z]?)`)
+
+// ParseVersion parses a version as tmux -V or a requirement writes it.
+// Development builds ("tmux master") have no version, and parse as nil.
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "tmux"))
+	if s == "master" {
+		return nil, nil
+	}
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, errgo.Newf("invalid tmux version %q", s)
+	}
+	var v Version
+	for _, part := range strings.Split(m[1], ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, errgo.Newf("invalid tmux version %q", s)
+		}
+		v = append(v, n)
+	}
+	if m[2] != "" {
+		v = append(v, int(m[2][0]-'a'+1))
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 as v is older than, the same as or newer than
+// w. A development build is newer than any release.
+func (v Version) Compare(w Version) int {
+	switch {
+	case v == nil && w == nil:
+		return 0
+	case v == nil:
+		return 1
+	case w == nil:
+		return -1
+	}
+	for i := 0; i < len(v) || i < len(w); i++ {
+		var a, b int
+		if i < len(v) {
+			a = v[i]
+		}
+		if i < len(w) {
+			b = w[i]
+		}
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Satisfies returns whether v meets every comma separated constraint in
+// constraints.
+func (v Version) Satisfies(constraints string) (bool, error) {
+	for _, c := range strings.Split(constraints, ",") {
+		c = strings.TrimSpace(c)
+		op := strings.TrimRight(c, "0123456789.abcdefghijklmnopqrstuvwxyz ")
+		want, err := ParseVersion(c[len(op):])
+		if err != nil {
+			return false, errgo.Mask(err)
+		}
+		cmp := v.Compare(want)
+		var ok bool
+		switch op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "", "=", "==":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		default:
+			return false, errgo.Newf("invalid version constraint %q", c)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Feature is a tmux feature, and the release that added it.
+type Feature struct {
+	Name  string
+	Since Version
+}
+
+// Features are tmux features that tmuxg, or sessions, may rely on.
+var Features = []Feature{
+	{"control mode", Version{1, 8}},
+	{"wait-for", Version{1, 8}},
+	{"hooks", Version{2, 2}},
+	{"menus", Version{3, 0}},
+	{"popups", Version{3, 2}},
+}
+
+// Supports returns whether release v has feature f.
+func (v Version) Supports(f Feature) bool {
+	return v.Compare(f.Since) >= 0
+}
+
+// String returns the version as dotted numbers, so 3.2a is 3.2.1.
+func (v Version) String() string {
+	if v == nil {
+		return "master"
+	}
+	var parts []string
+	for _, n := range v {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return strings.Join(parts, ".")
+}
+
+// InstalledVersion returns the version of the tmux that r runs, as reported
+// by tmux -V.
+func InstalledVersion(r Runner) (string, Version, error) {
+	out, err := r.Output("", []string{"-V"})
+	if err != nil {
+		return "", nil, errgo.Notef(err, "failed to get tmux version")
+	}
+	s := strings.TrimSpace(string(out))
+	v, err := ParseVersion(s)
+	if err != nil {
+		return "", nil, errgo.Mask(err)
+	}
+	return s, v, nil
+}
+
+// CheckRequirements fails if the machine doesn't meet the session's
+// requirements.
+func (s *Session) CheckRequirements() error {
+	if s.Requires.Tmux == "" {
+		return nil
+	}
+	installed, v, err := InstalledVersion(s.Runner)
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	ok, err := v.Satisfies(s.Requires.Tmux)
+	if err != nil {
+		return errgo.WithCausef(err, ErrRequirement, "")
+	}
+	if !ok {
+		return errgo.WithCausef(nil, ErrVersion,
+			"session %q requires tmux %s, but found %s", s.Name, s.Requires.Tmux, installed)
+	}
+	return nil
+}
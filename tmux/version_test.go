@@ -0,0 +1,74 @@
+package tmux_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cmars/tmuxg/tmux"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    tmux.Version
+		wantErr bool
+	}{
+		{s: "tmux 3.2", want: tmux.Version{3, 2}},
+		{s: "tmux 3.2a\n", want: tmux.Version{3, 2, 1}},
+		{s: "3.3a", want: tmux.Version{3, 3, 1}},
+		{s: "tmux next-3.4", want: tmux.Version{3, 4}},
+		{s: "tmux master", want: nil},
+		{s: "tmux", wantErr: true},
+	}
+	for _, test := range tests {
+		v, err := tmux.ParseVersion(test.s)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseVersion(%q): got %v, want an error", test.s, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseVersion(%q): %v", test.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, test.want) {
+			t.Errorf("ParseVersion(%q): got %v, want %v", test.s, v, test.want)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		version     tmux.Version
+		constraints string
+		want        bool
+		wantErr     bool
+	}{
+		{version: tmux.Version{3, 2}, constraints: ">=3.1", want: true},
+		{version: tmux.Version{3, 0}, constraints: ">=3.1", want: false},
+		{version: tmux.Version{3, 2, 1}, constraints: ">3.2", want: true},
+		{version: tmux.Version{3, 2, 1}, constraints: "3.2a", want: true},
+		{version: tmux.Version{3, 3}, constraints: ">=2.9, <3.3", want: false},
+		{version: tmux.Version{3, 1}, constraints: ">=2.9, <3.3", want: true},
+		{version: tmux.Version{3, 1}, constraints: "!=3.1", want: false},
+		{version: nil, constraints: ">=3.4", want: true},
+		{version: tmux.Version{3, 1}, constraints: "~3.1", wantErr: true},
+	}
+	for _, test := range tests {
+		ok, err := test.version.Satisfies(test.constraints)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v.Satisfies(%q): want an error", test.version, test.constraints)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v.Satisfies(%q): %v", test.version, test.constraints, err)
+			continue
+		}
+		if ok != test.want {
+			t.Errorf("%v.Satisfies(%q): got %v, want %v", test.version, test.constraints, ok, test.want)
+		}
+	}
+}
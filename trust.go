@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// signatureNamespace is the ssh-keygen -Y namespace session files are
+// signed in.
+const signatureNamespace = "tmuxg"
+
+// isShared returns whether the session file at path is one of the shared
+// session files, wherever links to it, or to the shared directory, lead.
+func isShared(path string) bool {
+	real, err := realPath(path)
+	if err != nil {
+		return false
+	}
+	shared, err := realPath(config.SharedDir())
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(shared, real)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath returns the absolute path of path, with links followed.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// verifySession checks that the session file at path, if it's a shared
+// one, is signed by someone trusted, if config.yaml says who that is. The
+// contents of a session file that was checked are returned, to be loaded
+// as they were checked, rather than read again.
+func verifySession(conf *config.Config, path string) ([]byte, error) {
+	if !conf.Trust.Enabled() || !isShared(path) {
+		return nil, nil
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to read session file %q", path)
+	}
+	var tried []string
+	if conf.Trust.AllowedSigners != "" {
+		if _, err := os.Stat(path + ".sig"); err == nil {
+			err = verifySSHSignature(os.ExpandEnv(expandHome(conf.Trust.AllowedSigners)), path, contents)
+			if err == nil {
+				return contents, nil
+			}
+			tried = append(tried, err.Error())
+		}
+	}
+	if conf.Trust.MinisignKey != "" {
+		if _, err := os.Stat(path + ".minisig"); err == nil {
+			err = verifyMinisignContents(os.ExpandEnv(expandHome(conf.Trust.MinisignKey)), path, contents)
+			if err == nil {
+				return contents, nil
+			}
+			tried = append(tried, err.Error())
+		}
+	}
+	if len(tried) == 0 {
+		return nil, errgo.WithCausef(nil, errUntrusted, "shared session file %q is not signed", path)
+	}
+	return nil, errgo.WithCausef(nil, errUntrusted, "shared session file %q is not signed by a trusted key: %s",
+		path, strings.Join(tried, "; "))
+}
+
+// verifySSHSignature checks path.sig, made with ssh-keygen -Y sign, against
+// the allowed signers file, as the signature of contents, read from path.
+func verifySSHSignature(allowedSigners, path string, contents []byte) error {
+	sig := path + ".sig"
+	out, err := exec.Command("ssh-keygen", "-Y", "find-principals", "-f", allowedSigners, "-s", sig).Output()
+	if err != nil {
+		return errgo.New("its signer is not an allowed signer")
+	}
+	principal := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	c := exec.Command("ssh-keygen", "-Y", "verify", "-f", allowedSigners,
+		"-I", principal, "-n", signatureNamespace, "-s", sig)
+	c.Stdin = bytes.NewReader(contents)
+	if out, err := c.CombinedOutput(); err != nil {
+		return errgo.Newf("ssh-keygen: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// verifyMinisignContents checks path.minisig, made with minisign -S,
+// against the public key file, as the signature of contents, read from path.
+func verifyMinisignContents(key, path string, contents []byte) error {
+	f, err := ioutil.TempFile("", "tmuxg-verify")
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	out, err := exec.Command("minisign", "-V", "-q", "-p", key, "-m", f.Name(), "-x", path+".minisig").CombinedOutput()
+	if err != nil {
+		return errgo.Newf("minisign: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// verifyMinisign checks path.minisig, made with minisign -S, against the
+// public key file.
+func verifyMinisign(key, path string) error {
+	out, err := exec.Command("minisign", "-V", "-q", "-p", key, "-m", path).CombinedOutput()
+	if err != nil {
+		return errgo.Newf("minisign: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// vagrantUp boots the Vagrant machines the session's windows run on, if
+// any.
+func vagrantUp(s *config.Session) error {
+	var machines []string
+	if !s.Vagrant {
+		seen := make(map[string]bool)
+		for _, w := range s.Windows {
+			if m := s.ExpandEnv(w.VagrantSSH); m != "" && !seen[m] {
+				seen[m] = true
+				machines = append(machines, m)
+			}
+		}
+		if len(machines) == 0 {
+			return nil
+		}
+	}
+	c := exec.Command("vagrant", append([]string{"up"}, machines...)...)
+	c.Env = s.Env()
+	if dir := s.ExpandEnv(s.Cwd); dir != "" {
+		c.Dir = dir
+	}
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	err := c.Run()
+	if err != nil {
+		return errgo.Notef(err, "failed to boot vagrant machines")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/config"
+	"github.com/cmars/tmuxg/tmux"
+)
+
+// version is the release tmuxg was built from, set by the release build
+// with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// versionCommand prints tmuxg's version and, with -verbose, how it was
+// built and what the installed tmux supports, for bug reports.
+func versionCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	verbose := fs.Bool("verbose", false, "also describe the build and the installed tmux")
+	args, err := parseCommandFlags("version", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("version", args, 0)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	fmt.Printf("tmuxg %s\n", version)
+	if !*verbose {
+		return nil
+	}
+
+	fmt.Printf("go:       %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				fmt.Printf("%-9s %s\n", setting.Key+":", setting.Value)
+			}
+		}
+	}
+
+	bin := conf.Tmux()
+	if path, err := exec.LookPath(bin); err == nil {
+		bin = path
+	}
+	installed, v, err := tmux.InstalledVersion(&tmux.ExecRunner{Bin: bin})
+	if err != nil {
+		fmt.Printf("tmux:     %s: %v\n", bin, err)
+		return nil
+	}
+	fmt.Printf("tmux:     %s (%s)\n", installed, bin)
+	for _, f := range tmux.Features {
+		supported := "yes"
+		if !v.Supports(f) {
+			supported = "no"
+		}
+		fmt.Printf("  %-14s %-3s (tmux %s and later)\n", f.Name, supported, f.Since)
+	}
+	return nil
+}
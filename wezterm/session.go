@@ -0,0 +1,283 @@
+// Package wezterm builds sessions described by tmuxg session models out of
+// WezTerm windows and tabs, using WezTerm's built in multiplexer.
+package wezterm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// Session is a WezTerm workspace built from a session model. Each of the
+// session's windows is a tab in a WezTerm window of its own.
+type Session struct {
+	*config.Session
+
+	// Bin is the wezterm executable to run.
+	Bin string
+
+	// windowID is the WezTerm window holding the session's tabs.
+	windowID string
+}
+
+var _ backend.Backend = (*Session)(nil)
+
+// New returns a Session for the given model.
+func New(s *config.Session) *Session {
+	return &Session{Session: s, Bin: "wezterm"}
+}
+
+// pane is a pane as listed by wezterm cli list.
+type pane struct {
+	WindowID  int    `json:"window_id"`
+	TabID     int    `json:"tab_id"`
+	PaneID    int    `json:"pane_id"`
+	Workspace string `json:"workspace"`
+	Title     string `json:"title"`
+	TabTitle  string `json:"tab_title"`
+}
+
+func (s *Session) cli(args ...string) ([]byte, error) {
+	c := exec.Command(s.Bin, append([]string{"cli"}, args...)...)
+	c.Stderr = os.Stderr
+	log.Printf("%v", c)
+	out, err := c.Output()
+	if err != nil {
+		return nil, errgo.Notef(err, "wezterm cli %s failed", args[0])
+	}
+	return out, nil
+}
+
+// panes returns the panes in the session's workspace.
+func (s *Session) panes() ([]pane, error) {
+	out, err := s.cli("list", "--format", "json")
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	var all []pane
+	err = json.Unmarshal(out, &all)
+	if err != nil {
+		return nil, errgo.Notef(err, "failed to parse wezterm pane list")
+	}
+	var panes []pane
+	for _, p := range all {
+		if p.Workspace == s.Name {
+			panes = append(panes, p)
+		}
+	}
+	return panes, nil
+}
+
+// Kill implements backend.Backend, closing every pane in the session's
+// workspace.
+func (s *Session) Kill() error {
+	panes, err := s.panes()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	for _, p := range panes {
+		_, err := s.cli("kill-pane", "--pane-id", strconv.Itoa(p.PaneID))
+		if err != nil {
+			return errgo.Notef(err, "failed to kill session %q", s.Name)
+		}
+	}
+	return nil
+}
+
+// spawn starts a pane running command in cwd, with the session's
+// environment, and returns its ID.
+func (s *Session) spawn(cwd, command string, args ...string) (string, error) {
+	args = append(args, "--cwd", cwd)
+	// Panes are spawned by the mux server, which doesn't share our
+	// environment, so the session's is passed along explicitly.
+	args = append(args, "--", "env")
+	args = append(args, s.environment()...)
+	args = append(args, "sh", "-c", command)
+	out, err := s.cli(args...)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CreateSession implements backend.Backend, opening a new WezTerm window in
+// the session's workspace.
+func (s *Session) CreateSession(w *config.Window) (string, error) {
+	paneID, err := s.spawn(backend.WindowCwd(s.Session, w), backend.WindowCommand(s.Session, w),
+		"spawn", "--new-window", "--workspace", s.Name)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to create window %q", w.Name)
+	}
+	panes, err := s.panes()
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	for _, p := range panes {
+		if strconv.Itoa(p.PaneID) == paneID {
+			s.windowID = strconv.Itoa(p.WindowID)
+		}
+	}
+	if s.windowID == "" {
+		return "", errgo.Newf("cannot find window of new pane %s", paneID)
+	}
+	s.setTitle(paneID, w.Name)
+	return paneID, nil
+}
+
+// CreateWindow implements backend.Backend, opening a new tab.
+func (s *Session) CreateWindow(i int, w *config.Window) (string, error) {
+	if w.LinkWindow != "" {
+		return "", errgo.Newf("WezTerm can't link window %q from another session", w.Name)
+	}
+	paneID, err := s.spawn(backend.WindowCwd(s.Session, w), backend.WindowCommand(s.Session, w),
+		"spawn", "--window-id", s.windowID)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to create window %q", w.Name)
+	}
+	s.setTitle(paneID, w.Name)
+	return paneID, nil
+}
+
+func (s *Session) setTitle(paneID, title string) {
+	if _, err := s.cli("set-tab-title", "--pane-id", paneID, title); err != nil {
+		log.Printf("failed to name window %q: %v", title, err)
+	}
+}
+
+// SplitPane implements backend.Backend.
+func (s *Session) SplitPane(target, cwd, command string) (string, error) {
+	paneID, err := s.spawn(cwd, command, "split-pane", "--pane-id", target)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to split pane %s", target)
+	}
+	return paneID, nil
+}
+
+// SendKeys implements backend.Backend.
+func (s *Session) SendKeys(target string, keystrokes []string) error {
+	_, err := s.cli("send-text", "--pane-id", target, "--no-paste", "--", keystrokesText(keystrokes))
+	return errgo.Mask(err)
+}
+
+// SendText implements backend.Backend.
+func (s *Session) SendText(target, text string) error {
+	_, err := s.cli("send-text", "--pane-id", target, "--no-paste", "--", text)
+	return errgo.Mask(err)
+}
+
+// Paste implements backend.Backend, with send-text's bracketed paste.
+func (s *Session) Paste(target, text string) error {
+	_, err := s.cli("send-text", "--pane-id", target, "--", text)
+	return errgo.Mask(err)
+}
+
+// Focus implements backend.Backend.
+func (s *Session) Focus(target string) error {
+	_, err := s.cli("activate-pane", "--pane-id", target)
+	return errgo.Mask(err)
+}
+
+// Flush implements backend.Backend. WezTerm commands aren't deferred.
+func (s *Session) Flush() error {
+	return nil
+}
+
+// Query implements backend.Backend.
+func (s *Session) Query() (*backend.State, error) {
+	panes, err := s.panes()
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	state := &backend.State{Running: len(panes) > 0}
+	tabs := make(map[int]bool)
+	for _, p := range panes {
+		if s.windowID == "" {
+			// So that windows can be added to a running session.
+			s.windowID = strconv.Itoa(p.WindowID)
+		}
+		if !tabs[p.TabID] {
+			tabs[p.TabID] = true
+			state.Windows = append(state.Windows, p.TabTitle)
+		}
+	}
+	return state, nil
+}
+
+// environment returns the session's environment as NAME=value arguments
+// for env.
+func (s *Session) environment() []string {
+	var env []string
+	for k := range s.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, s.Getenv(k)))
+	}
+	sort.Strings(env)
+	return env
+}
+
+// keyText is the text typed for tmux key names.
+var keyText = map[string]string{
+	"Enter":  "\r",
+	"Tab":    "\t",
+	"BTab":   "\x1b[Z",
+	"Escape": "\x1b",
+	"Space":  " ",
+	"BSpace": "\x7f",
+	"DC":     "\x1b[3~",
+	"IC":     "\x1b[2~",
+	"Up":     "\x1b[A",
+	"Down":   "\x1b[B",
+	"Right":  "\x1b[C",
+	"Left":   "\x1b[D",
+	"Home":   "\x1b[H",
+	"End":    "\x1b[F",
+	"PPage":  "\x1b[5~",
+	"NPage":  "\x1b[6~",
+}
+
+// keystrokesText returns the text to type for tmux send-keys style
+// keystrokes. WezTerm sends text rather than keys, so key names are
+// translated to what a terminal would send for them, and anything else is
+// typed as it is.
+func keystrokesText(keystrokes []string) string {
+	var text strings.Builder
+	for _, k := range keystrokes {
+		k = backend.KeyName(k)
+		switch {
+		case keyText[k] != "":
+			text.WriteString(keyText[k])
+		case len(k) == 3 && strings.HasPrefix(k, "C-") && k[2] >= '@' && k[2] <= '~':
+			// Control characters are the key's code with the top
+			// bits cleared.
+			text.WriteByte(k[2] & 0x1f)
+		case len(k) == 3 && strings.HasPrefix(k, "M-"):
+			text.WriteString("\x1b" + k[2:])
+		default:
+			text.WriteString(k)
+		}
+	}
+	return text.String()
+}
+
+// Attach implements backend.Backend, switching WezTerm to the session's
+// workspace. None of the options apply to WezTerm.
+func (s *Session) Attach(opts backend.AttachOptions) error {
+	panes, err := s.panes()
+	if err != nil {
+		return errgo.Mask(err)
+	}
+	if len(panes) == 0 {
+		return errgo.Newf("session %q is not running", s.Name)
+	}
+	_, err = s.cli("activate-pane", "--pane-id", strconv.Itoa(panes[0].PaneID))
+	return errgo.Mask(err)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cmars/tmuxg/config"
+)
+
+// wizard asks the user questions on the terminal.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prompts for an answer until validate accepts it. An empty answer is
+// def, if def is not empty.
+func (w *wizard) ask(prompt, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", prompt, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", prompt)
+		}
+		line, err := w.in.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return "", errgo.New("no answer given")
+		} else if err != nil && err != io.EOF {
+			return "", errgo.Mask(err)
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(w.out, "  %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// validSessionName checks a new session's name.
+func validSessionName(name string) error {
+	switch {
+	case name == "":
+		return errgo.New("the session needs a name")
+	case strings.ContainsAny(name, "/.:"):
+		return errgo.New("session names can't contain '/', '.' or ':'")
+	case name == "config":
+		return errgo.New("config is reserved for tmuxg's own config file")
+	}
+	if _, ok := commands[name]; ok {
+		return errgo.Newf("%s is a tmuxg command", name)
+	}
+	if path, err := config.Locate(name); err == nil {
+		return errgo.Newf("session %q already exists in %q", name, path)
+	}
+	return nil
+}
+
+// runWizard asks the user about a new session, and writes its session file
+// to the config directory. If name is empty, the wizard asks for it too.
+func runWizard(in io.Reader, out io.Writer, name string) (string, error) {
+	w := &wizard{in: bufio.NewReader(in), out: out}
+	var s config.Session
+	var err error
+
+	if name == "" {
+		name, err = w.ask("Session name", "", validSessionName)
+	} else {
+		err = validSessionName(name)
+	}
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	s.Name = name
+
+	s.Description, err = w.ask("Description (optional)", "", nil)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+
+	wd, _ := os.Getwd()
+	s.Cwd, err = w.ask("Working directory", wd, func(dir string) error {
+		if !filepath.IsAbs(os.ExpandEnv(dir)) {
+			return errgo.New("give an absolute path, which may use environment variables")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+
+	fmt.Fprintln(out, "Windows, in order. Give an empty name when done.")
+	names := map[string]bool{}
+	for {
+		def := ""
+		if len(s.Windows) == 0 {
+			def = "shell"
+		}
+		var win config.Window
+		win.Name, err = w.ask(fmt.Sprintf("Window %d name", len(s.Windows)), def, func(name string) error {
+			if names[name] {
+				return errgo.Newf("there's already a window named %q", name)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", errgo.Mask(err)
+		}
+		if win.Name == "" {
+			break
+		}
+		names[win.Name] = true
+		win.Command, err = w.ask("  Command", "bash", nil)
+		if err != nil {
+			return "", errgo.Mask(err)
+		}
+		if win.Command == "bash" {
+			win.Command = ""
+		}
+		win.Cwd, err = w.ask("  Working directory (empty for the session's)", "", nil)
+		if err != nil {
+			return "", errgo.Mask(err)
+		}
+		s.Windows = append(s.Windows, win)
+	}
+
+	s.Focus, err = w.ask("Window to focus", s.Windows[0].Name, func(name string) error {
+		if !names[name] {
+			return errgo.Newf("there's no window named %q", name)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+
+	contents, err := yaml.Marshal(&s)
+	if err != nil {
+		return "", errgo.Mask(err)
+	}
+	err = os.MkdirAll(config.Dir(), 0755)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to create config directory")
+	}
+	confPath := filepath.Join(config.Dir(), name+".yaml")
+	err = ioutil.WriteFile(confPath, contents, 0644)
+	if err != nil {
+		return "", errgo.Notef(err, "failed to write session file %q", confPath)
+	}
+	fmt.Fprintf(out, "Wrote %s\n", confPath)
+	return confPath, nil
+}
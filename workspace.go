@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// workspaceCommand starts the sessions of a workspace, and attaches to one
+// of them.
+func workspaceCommand(conf *config.Config, args []string) error {
+	fs := flag.NewFlagSet("workspace", flag.ContinueOnError)
+	attach := fs.String("attach", "", "session to attach to, instead of the workspace's")
+	args, err := parseCommandFlags("workspace", fs, args)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	err = commandArgs("workspace", args, 2)
+	if err == nil && args[0] != "up" {
+		err = commandArgs("workspace", nil, 2)
+	}
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	path, err := config.LocateWorkspace(args[1])
+	if os.IsNotExist(err) {
+		return errgo.WithCausef(err, errConfigNotFound, "no workspace %q", args[1])
+	} else if err != nil {
+		return errgo.Mask(err)
+	}
+	ws, err := config.LoadWorkspace(path)
+	if err != nil {
+		return errgo.WithCausef(err, errParse, "")
+	}
+	if *attach != "" {
+		ws.Attach = *attach
+	}
+
+	noAttach := *noAttachFlag
+	defer func() { *noAttachFlag = noAttach }()
+	for _, name := range ws.Sessions {
+		if name == ws.Attach {
+			continue
+		}
+		*noAttachFlag = true
+		err := startWorkspaceSession(conf, name)
+		if err != nil {
+			return errgo.Notef(err, "failed to start session %q of workspace %q", name, ws.Name)
+		}
+	}
+	*noAttachFlag = noAttach
+	return errgo.Mask(startWorkspaceSession(conf, ws.Attach), errgo.Any)
+}
+
+// startWorkspaceSession starts a session of a workspace, which must have a
+// session file already.
+func startWorkspaceSession(conf *config.Config, name string) error {
+	s, err := loadSession(conf, name)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	b, err := newBackend(conf, s)
+	if err != nil {
+		return errgo.Mask(err, errgo.Any)
+	}
+	if !backend.Running(b) {
+		err = startRequiredSessions(conf, s)
+		if err != nil {
+			return errgo.Mask(err, errgo.Any)
+		}
+	}
+	debugEnvironment(s)
+	return errgo.Mask(startBackend(b, s, s.Name, localSetup(s)), errgo.Any)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/errgo.v1"
+
+	"github.com/cmars/tmuxg/backend"
+	"github.com/cmars/tmuxg/config"
+)
+
+// worktree is a git worktree.
+type worktree struct {
+	path, branch string
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return nil, errgo.Notef(err, "git %s failed", args[0])
+	}
+	return out, nil
+}
+
+// listWorktrees returns the worktrees of the repository containing dir.
+func listWorktrees(dir string) ([]worktree, error) {
+	out, err := git(dir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	var worktrees []worktree
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			path := strings.TrimPrefix(line, "worktree ")
+			worktrees = append(worktrees, worktree{path: path, branch: filepath.Base(path)})
+		case strings.HasPrefix(line, "branch ") && len(worktrees) > 0:
+			worktrees[len(worktrees)-1].branch = strings.TrimPrefix(line, "branch refs/heads/")
+		}
+	}
+	return worktrees, nil
+}
+
+// windowWorktrees returns the worktrees a window is repeated for, adding
+// worktrees for its branches that don't have one.
+func windowWorktrees(s *config.Session, w *config.Window) ([]worktree, error) {
+	dir := backend.WindowCwd(s, w)
+	existing, err := listWorktrees(dir)
+	if err != nil {
+		return nil, errgo.Mask(err)
+	}
+	if len(w.Worktrees.Branches) == 0 {
+		return existing, nil
+	}
+
+	var worktrees []worktree
+	for _, branch := range w.Worktrees.Branches {
+		branch = s.ExpandEnv(branch)
+		found := false
+		for _, wt := range existing {
+			if wt.branch == branch {
+				worktrees = append(worktrees, wt)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		root := existing[0].path
+		path := filepath.Join(filepath.Dir(root), filepath.Base(root)+"-"+strings.Replace(branch, "/", "-", -1))
+		if w.Worktrees.Dir != "" {
+			path = filepath.Join(s.ExpandEnv(w.Worktrees.Dir), branch)
+		}
+		log.Printf("adding worktree %q for branch %q", path, branch)
+		_, err := git(dir, "worktree", "add", path, branch)
+		if err != nil {
+			return nil, errgo.Notef(err, "failed to add worktree for branch %q", branch)
+		}
+		worktrees = append(worktrees, worktree{path: path, branch: branch})
+	}
+	return worktrees, nil
+}
+
+// expandWorktrees replaces the session's worktree windows with a window for
+// each of their worktrees. A run of worktree windows is repeated together
+// for each worktree, so that each gets, say, an editor and a shell side by
+// side.
+func expandWorktrees(s *config.Session) error {
+	var windows []config.Window
+	for i := 0; i < len(s.Windows); {
+		if s.Windows[i].Worktrees == nil {
+			windows = append(windows, s.Windows[i])
+			i++
+			continue
+		}
+
+		var paths []string
+		byPath := make(map[string][]config.Window)
+		for ; i < len(s.Windows) && s.Windows[i].Worktrees != nil; i++ {
+			w := s.Windows[i]
+			worktrees, err := windowWorktrees(s, &w)
+			if err != nil {
+				return errgo.Notef(err, "failed to find worktrees for window %q", w.Name)
+			}
+			w.Worktrees = nil
+			for _, wt := range worktrees {
+				if _, ok := byPath[wt.path]; !ok {
+					paths = append(paths, wt.path)
+				}
+				ww := w
+				ww.Name = w.Name + "/" + wt.branch
+				ww.Cwd = wt.path
+				byPath[wt.path] = append(byPath[wt.path], ww)
+			}
+		}
+		for _, path := range paths {
+			windows = append(windows, byPath[path]...)
+		}
+	}
+	s.Windows = windows
+	return nil
+}